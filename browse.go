@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samassembly/gator/internal/database"
+	"github.com/samassembly/gator/internal/htmltext"
+)
+
+//what browse shows of each post
+const (
+	//whichever of the full content and the summary says more
+	browseAuto    = "auto"
+	browseSummary = "summary"
+	browseFull    = "full"
+)
+
+type browseOptions struct {
+	limit int32
+	view  string
+	//only posts by an author whose name contains this, and in this category
+	author   sql.NullString
+	category sql.NullString
+}
+
+//browse through posts saved in the database
+func handlerBrowse(ctx context.Context, s *state, cmd command, user database.User) error {
+	opts, err := parseBrowseArgs(cmd.Args)
+	if err != nil {
+		return fmt.Errorf("usage: %s [limit] [--summary|--full] [--author <name>] [--category <name>]: %v", cmd.Name, err)
+	}
+
+	userid := user.ID
+	GetPostsForUserParams := database.GetPostsForUserParams{
+		UserID:   userid,
+		Author:   opts.author,
+		Category: opts.category,
+		MaxPosts: opts.limit,
+	}
+
+	posts, err := s.db.GetPostsForUser(ctx, GetPostsForUserParams)
+	if err != nil {
+		return fmt.Errorf("Error retrieving posts: %v\n", err)
+	}
+
+	//enclosures of every listed post in one go
+	postIDs := make([]uuid.UUID, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+	enclosures, err := s.db.GetEnclosuresForPosts(ctx, postIDs)
+	if err != nil {
+		return fmt.Errorf("Error retrieving enclosures: %v\n", err)
+	}
+	postEnclosures := map[uuid.UUID][]database.PostEnclosure{}
+	for _, enclosure := range enclosures {
+		postEnclosures[enclosure.PostID] = append(postEnclosures[enclosure.PostID], enclosure)
+	}
+	authors, err := s.db.GetAuthorsForPosts(ctx, postIDs)
+	if err != nil {
+		return fmt.Errorf("Error retrieving authors: %v\n", err)
+	}
+	postAuthors := map[uuid.UUID][]string{}
+	for _, author := range authors {
+		postAuthors[author.PostID] = append(postAuthors[author.PostID], htmltext.CleanText(author.Name))
+	}
+	categories, err := s.db.GetCategoriesForPosts(ctx, postIDs)
+	if err != nil {
+		return fmt.Errorf("Error retrieving categories: %v\n", err)
+	}
+	postCategories := map[uuid.UUID][]string{}
+	for _, category := range categories {
+		postCategories[category.PostID] = append(postCategories[category.PostID], htmltext.CleanText(category.Name))
+	}
+
+	if len(posts) == 0 && (opts.author.Valid || opts.category.Valid) {
+		fmt.Println("No posts match the filter")
+		return nil
+	}
+
+	//everything printed comes from feeds, so nothing reaches the terminal
+	//without control characters stripped
+	width := terminalWidth()
+	for _, post := range posts {
+		published := "undated"
+		if post.PublishedAt.Valid {
+			published = post.PublishedAt.Time.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%s\n", htmltext.CleanText(post.Title))
+		fmt.Printf("  %s | %s | %s\n", htmltext.CleanText(post.FeedName), published, htmltext.CleanText(post.Url))
+		if names := postAuthors[post.ID]; len(names) > 0 {
+			fmt.Printf("  by %s\n", strings.Join(names, ", "))
+		}
+		if names := postCategories[post.ID]; len(names) > 0 {
+			fmt.Printf("  in %s\n", strings.Join(names, ", "))
+		}
+		if body := htmltext.Render(postBody(post, opts.view), width); body != "" {
+			fmt.Printf("%s\n", body)
+		}
+		for _, enclosure := range postEnclosures[post.ID] {
+			fmt.Printf("  %s\n", htmltext.CleanText(describeEnclosure(enclosure)))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func parseBrowseArgs(args []string) (browseOptions, error) {
+	opts := browseOptions{
+		limit: 2,
+		view:  browseAuto,
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--summary":
+			opts.view = browseSummary
+		case "--full":
+			opts.view = browseFull
+		case "--author", "--category":
+			if i+1 >= len(args) || strings.TrimSpace(args[i+1]) == "" {
+				return opts, fmt.Errorf("%s needs a name", arg)
+			}
+			i++
+			value := sql.NullString{String: strings.TrimSpace(args[i]), Valid: true}
+			if arg == "--author" {
+				opts.author = value
+			} else {
+				opts.category = value
+			}
+		default:
+			num, err := strconv.Atoi(arg)
+			if err != nil {
+				return opts, fmt.Errorf("invalid number: %v", err)
+			}
+			if num != 0 {
+				opts.limit = int32(num)
+			}
+		}
+	}
+	return opts, nil
+}
+
+//the text to show for a post, each view falls back to the other field when its own is empty
+func postBody(post database.GetPostsForUserRow, view string) string {
+	summary := strings.TrimSpace(post.Description.String)
+	content := strings.TrimSpace(post.Content.String)
+	switch view {
+	case browseSummary:
+		if summary != "" {
+			return summary
+		}
+		return content
+	case browseFull:
+		if content != "" {
+			return content
+		}
+		return summary
+	}
+	if len(content) > len(summary) {
+		return content
+	}
+	return summary
+}
+
+//columns to wrap posts at, shells export COLUMNS when asked to and a
+//classic terminal is 80 wide
+func terminalWidth() int {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width < 40 {
+		return 80
+	}
+	return width
+}
+
+//one line per enclosure, with whatever the feed told about it
+func describeEnclosure(enclosure database.PostEnclosure) string {
+	if enclosure.Thumbnail {
+		return fmt.Sprintf("image: %s", enclosure.Url)
+	}
+	var details []string
+	if enclosure.MimeType.Valid {
+		details = append(details, enclosure.MimeType.String)
+	}
+	if enclosure.Length.Valid {
+		details = append(details, fmt.Sprintf("%.1f MB", float64(enclosure.Length.Int64)/(1<<20)))
+	}
+	if enclosure.DurationSeconds.Valid {
+		details = append(details, (time.Duration(enclosure.DurationSeconds.Int32) * time.Second).String())
+	}
+	if len(details) == 0 {
+		return fmt.Sprintf("enclosure: %s", enclosure.Url)
+	}
+	return fmt.Sprintf("enclosure: %s (%s)", enclosure.Url, strings.Join(details, ", "))
+}
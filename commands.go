@@ -1,6 +1,9 @@
 package main
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 type command struct {
 	Name string
@@ -8,17 +11,17 @@ type command struct {
 }
 
 type commands struct {
-	registeredCommands map[string]func(*state, command) error
+	registeredCommands map[string]func(context.Context, *state, command) error
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
+func (c *commands) register(name string, f func(context.Context, *state, command) error) {
 	c.registeredCommands[name] = f
 }
 
-func (c *commands) run(s *state, cmd command) error {
+func (c *commands) run(ctx context.Context, s *state, cmd command) error {
 	f, ok := c.registeredCommands[cmd.Name]
 	if !ok {
 		return errors.New("command not found")
 	}
-	return f(s, cmd)
+	return f(ctx, s, cmd)
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/samassembly/gator/internal/database"
+)
+
+const (
+	//enclosures fetched by download unless a limit is given
+	defaultDownloadLimit = 10
+	//suffix of files still being downloaded, a rerun picks up where they stopped
+	partialSuffix = ".part"
+)
+
+//fetch the enclosures of the latest posts on followed feeds into a directory,
+//one folder per feed
+func handlerDownload(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		return fmt.Errorf("usage: %s <dir> [limit]", cmd.Name)
+	}
+	dir := cmd.Args[0]
+	limit := defaultDownloadLimit
+	if len(cmd.Args) == 2 {
+		num, err := strconv.Atoi(cmd.Args[1])
+		if err != nil || num < 1 {
+			return fmt.Errorf("invalid limit: %s", cmd.Args[1])
+		}
+		limit = num
+	}
+
+	fetcher, err := newFetcher(s.cfg.Fetch)
+	if err != nil {
+		return fmt.Errorf("invalid fetch config: %w", err)
+	}
+	//same proxy and tls settings as feeds, but no overall timeout on big files
+	client := &http.Client{}
+	if fetcher.Client != nil {
+		client.Transport = fetcher.Client.Transport
+	}
+	userAgent := fetcher.UserAgent
+	if userAgent == "" {
+		userAgent = "gator"
+	}
+
+	enclosures, err := s.db.GetEnclosuresToDownload(ctx, database.GetEnclosuresToDownloadParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("Error retrieving enclosures: %v", err)
+	}
+
+	if len(enclosures) == 0 {
+		fmt.Println("No enclosures on followed feeds")
+		return nil
+	}
+
+	failed := 0
+	for _, enclosure := range enclosures {
+		feedDir := filepath.Join(dir, safeFileName(enclosure.FeedName))
+		err := os.MkdirAll(feedDir, 0o755)
+		if err != nil {
+			return fmt.Errorf("Could not create %s: %v", feedDir, err)
+		}
+		target := filepath.Join(feedDir, enclosureFileName(enclosure))
+
+		if _, err := os.Stat(target); err == nil {
+			fmt.Printf("Already downloaded %s\n", target)
+			continue
+		}
+		fmt.Printf("Downloading %s\n", enclosure.Url)
+		err = downloadFile(ctx, client, userAgent, enclosure.Url, target)
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted, run download again to resume")
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("Could not download %s: %v\n", enclosure.Url, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Saved %s\n", target)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d downloads failed, run download again to retry", failed)
+	}
+	return nil
+}
+
+//download into target via a partial file, resuming it with a range request
+//when an earlier run left one behind
+func downloadFile(ctx context.Context, client *http.Client, userAgent, fileURL, target string) error {
+	partial := target + partialSuffix
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		//nothing left past the end of the partial file
+		return os.Rename(partial, target)
+	case resp.StatusCode == http.StatusOK:
+		//the server ignored the range, start over
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	file, err := os.OpenFile(partial, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, resp.Body)
+	closeErr := file.Close()
+	if err != nil {
+		//the partial file stays for the next run to resume
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Rename(partial, target)
+}
+
+//name a download after its post, keeping the extension of the file
+func enclosureFileName(enclosure database.GetEnclosuresToDownloadRow) string {
+	base := ""
+	if u, err := url.Parse(enclosure.Url); err == nil {
+		base = path.Base(u.Path)
+	}
+	ext := path.Ext(base)
+	if ext == "" && enclosure.MimeType.Valid {
+		if exts, err := mime.ExtensionsByType(enclosure.MimeType.String); err == nil && len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+
+	name := safeFileName(enclosure.PostTitle)
+	if name == "" {
+		name = safeFileName(strings.TrimSuffix(base, ext))
+	}
+	if name == "" {
+		return enclosure.ID.String() + ext
+	}
+	//posts can share a title and carry several enclosures, a hash of the url
+	//tells their files apart and stays the same when a post is edited and its
+	//enclosures stored again
+	sum := sha256.Sum256([]byte(enclosure.Url))
+	return name + " [" + hex.EncodeToString(sum[:4]) + "]" + ext
+}
+
+//strip what file systems refuse or treat specially, and keep names short
+func safeFileName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			continue
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+		if b.Len() >= 120 {
+			break
+		}
+	}
+	return strings.Trim(b.String(), " .")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/samassembly/gator/internal/config"
+	"github.com/samassembly/gator/internal/rss"
+)
+
+//build the feed fetcher from the "fetch" section of the config
+func newFetcher(cfg *config.FetchConfig) (*rss.Fetcher, error) {
+	if cfg == nil {
+		return &rss.Fetcher{}, nil
+	}
+	opts := rss.FetcherOptions{
+		Proxy:       cfg.Proxy,
+		UserAgent:   cfg.UserAgent,
+		MaxBodySize: cfg.MaxBodyBytes,
+	}
+
+	var err error
+	if cfg.Timeout != "" {
+		opts.Timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetch timeout: %w", err)
+		}
+	}
+
+	if len(cfg.Headers) > 0 {
+		opts.Headers = http.Header{}
+		for name, value := range cfg.Headers {
+			opts.Headers.Set(name, value)
+		}
+	}
+
+	if cfg.HostConcurrency > 0 || cfg.HostInterval != "" {
+		concurrency := cfg.HostConcurrency
+		if concurrency <= 0 {
+			concurrency = 2
+		}
+		interval := time.Second
+		if cfg.HostInterval != "" {
+			interval, err = time.ParseDuration(cfg.HostInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid host interval: %w", err)
+			}
+		}
+		opts.Limiter = rss.NewHostLimiter(concurrency, interval)
+	}
+
+	if cfg.TLS != nil {
+		opts.TLS, err = tlsConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rss.NewFetcher(opts)
+}
+
+func tlsConfig(cfg *config.TLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	switch cfg.MinVersion {
+	case "":
+	case "1.2":
+		tlsCfg.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsCfg.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported tls min_version: %s", cfg.MinVersion)
+	}
+
+	if cfg.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ca_file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
@@ -3,6 +3,8 @@ module github.com/samassembly/gator
 go 1.24.1
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.10.9
+	golang.org/x/net v0.46.0
+	golang.org/x/text v0.30.0
 )
@@ -1,26 +1,27 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 	"context"
 	"log"
-	"strings"
 	"strconv"
-	"database/sql"
+	"strings"
 	"github.com/samassembly/gator/internal/database"
 	"github.com/samassembly/gator/internal/rss"
 	"github.com/google/uuid"
 )
 
 //set a registered db user as the current use in the configuration
-func handlerLogin(s *state, cmd command) error {
+func handlerLogin(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("usage: %s <name>", cmd.Name)
 	}
 	name := cmd.Args[0]
 
-	_, err := s.db.GetUser(context.Background(), name)
+	_, err := s.db.GetUser(ctx, name)
 	if err != nil {
 		return fmt.Errorf("User not registered: %v", err)
 	}
@@ -35,7 +36,7 @@ func handlerLogin(s *state, cmd command) error {
 }
 
 //register a new user in the db
-func handlerRegister(s *state, cmd command) error {
+func handlerRegister(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("usage: %s <name>", cmd.Name)
 	}
@@ -52,7 +53,7 @@ func handlerRegister(s *state, cmd command) error {
 		Name: name,
 	}
 
-	user, err := s.db.CreateUser(context.Background(), create_args)
+	user, err := s.db.CreateUser(ctx, create_args)
 	if err != nil {
 		return fmt.Errorf("Failed to create user: %v\n", err)
 	}
@@ -67,8 +68,8 @@ func handlerRegister(s *state, cmd command) error {
 }
 
 //retrieve users in the db
-func handlerUsers(s *state, cmd command) error {
-	users, err := s.db.GetUsers(context.Background())
+func handlerUsers(ctx context.Context, s *state, cmd command) error {
+	users, err := s.db.GetUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("Failed to retrieve users: %v\n", err)
 	}
@@ -83,10 +84,10 @@ func handlerUsers(s *state, cmd command) error {
 	return nil
 }
 
-//fetch the feed for a given url
-func handlerAgg(s *state, cmd command) error {
+//fetch feeds on an interval with a pool of concurrent workers
+func handlerAgg(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
-		return fmt.Errorf("usage: %v <time_between_reqs>", cmd.Name)
+		return fmt.Errorf("usage: %v <time_between_reqs> [workers]", cmd.Name)
 	}
 
 	timeBetweenRequests, err := time.ParseDuration(cmd.Args[0])
@@ -94,84 +95,90 @@ func handlerAgg(s *state, cmd command) error {
 		return fmt.Errorf("invalid duration: %w", err)
 	}
 
-	log.Printf("Collecting feeds every %s...", timeBetweenRequests)
+	workers := 1
+	if len(cmd.Args) == 2 {
+		workers, err = strconv.Atoi(cmd.Args[1])
+		if err != nil || workers < 1 {
+			return fmt.Errorf("invalid worker count: %s", cmd.Args[1])
+		}
+	}
 
-	ticker := time.NewTicker(timeBetweenRequests)
+	log.Printf("Collecting feeds every %s with %d workers...", timeBetweenRequests, workers)
 
-	for ; ; <-ticker.C {
-		scrapeFeeds(s)
+	deadThreshold := s.cfg.DeadFeedFailures
+	if deadThreshold <= 0 {
+		deadThreshold = defaultDeadFeedFailures
 	}
-}
 
-func scrapeFeeds(s *state) {
-	feed, err := s.db.GetNextFeedToFetch(context.Background())
+	fetcher, err := newFetcher(s.cfg.Fetch)
 	if err != nil {
-		log.Println("Couldn't get next feeds to fetch", err)
-		return
+		return fmt.Errorf("invalid fetch config: %w", err)
 	}
-	log.Println("Found a feed to fetch!")
-	scrapeFeed(s.db, feed)
-}
 
-func scrapeFeed(db *database.Queries, feed database.Feed) {
-	_, err := db.MarkFeedFetched(context.Background(), feed.ID)
-	if err != nil {
-		log.Printf("Couldn't mark feed %s fetched: %v", feed.Name, err)
-		return
-	}
+	start := time.Now()
+	agg := newAggregator(s.db, s.conn, fetcher, timeBetweenRequests, workers, deadThreshold)
+	agg.startWorkers(ctx, workers)
 
-	feedData, err := rss.FetchFeed(context.Background(), feed.Url)
-	if err != nil {
-		log.Printf("Couldn't collect feed %s: %v", feed.Name, err)
-		return
-	}
-	for _, item := range feedData.Channel.Item {
-		//fmt.Printf("Found post: %s\n", item.Title)
-		publishedAt := sql.NullTime{}
-		if t, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
-			publishedAt = sql.NullTime{
-				Time:  t,
-				Valid: true,
-			}
-		}
-		_, err = db.CreatePost(context.Background(), database.CreatePostParams{
-			ID:        uuid.New(),
-			CreatedAt: time.Now().UTC(),
-			UpdatedAt: time.Now().UTC(),
-			FeedID:    feed.ID,
-			Title:     item.Title,
-			Description: sql.NullString{
-				String: item.Description,
-				Valid:  true,
-			},
-			Url:         item.Link,
-			PublishedAt: publishedAt,
-		})
-		if err != nil {
-			if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-				continue
-			}
-			log.Printf("Couldn't create post: %v", err)
-			continue
+	ticker := time.NewTicker(timeBetweenRequests)
+	defer ticker.Stop()
+
+	for {
+		agg.scrapeFeeds(ctx)
+		select {
+		case <-ctx.Done():
+			log.Println("Shutting down, waiting for workers to finish...")
+			agg.stop()
+			log.Println(agg.stats.summary(time.Since(start)))
+			return nil
+		case <-ticker.C:
 		}
-
 	}
-	log.Printf("Feed %s collected, %v posts found", feed.Name, len(feedData.Channel.Item))
 }
 
 //add a specified feed to the db 
-func handlerAddFeed(s *state, cmd command, user database.User) error {
+func handlerAddFeed(ctx context.Context, s *state, cmd command, user database.User) error {
 	userid := user.ID
 
-	if len(cmd.Args) != 2 {
-		return fmt.Errorf("usage: %s <name> <url>", cmd.Name)
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		return fmt.Errorf("usage: %s [name] <url>", cmd.Name)
+	}
+	name := ""
+	if len(cmd.Args) == 2 {
+		name = cmd.Args[0]
+	}
+
+	fetcher, err := newFetcher(s.cfg.Fetch)
+	if err != nil {
+		return fmt.Errorf("invalid fetch config: %w", err)
+	}
+	//nothing is stored until the url is known to serve a feed
+	url, feedData, info, err := fetchNewFeed(ctx, fetcher, cmd.Args[len(cmd.Args)-1])
+	if err != nil {
+		return err
+	}
+	if info.FinalURL != "" {
+		feedData.ResolveLinks(info.FinalURL)
+	} else {
+		feedData.ResolveLinks(url)
+	}
+	if name == "" {
+		name = strings.TrimSpace(feedData.Title)
+	}
+	if name == "" {
+		name = url
+	}
+
+	//the feed, its follow and its first posts go in together or not at all
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Could not start transaction: %v", err)
 	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
 
 	id := uuid.New()
 	created_at := time.Now()
 	updated_at := time.Now()
-	name := cmd.Args[0]
-	url := cmd.Args[1]
 
 	create_args := database.CreateFeedParams{
 		ID: id,
@@ -180,9 +187,17 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 		Name: name,
 		Url: url,
 		UserID: userid, 
+		SiteUrl: sql.NullString{
+			String: feedData.Link,
+			Valid:  feedData.Link != "",
+		},
+		Description: sql.NullString{
+			String: feedData.Description,
+			Valid:  feedData.Description != "",
+		},
 	}
 
-	feed, err := s.db.CreateFeed(context.Background(), create_args)
+	feed, err := qtx.CreateFeed(ctx, create_args)
 	if err != nil {
 		return fmt.Errorf("Failed to add feed to database: %v\n", err)
 	}
@@ -198,33 +213,231 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 		UserID: userid,
 		FeedID: feedid,
 	}
-	_, err = s.db.CreateFeedFollow(context.Background(), follow_args)
+	_, err = qtx.CreateFeedFollow(ctx, follow_args)
 	if err != nil {
 		return fmt.Errorf("Could not create feed_follow: %v", err)
 	}
 
+	//the first agg run can then ask for changes only
+	err = qtx.SetFeedCacheHeaders(ctx, database.SetFeedCacheHeadersParams{
+		ID: feedid,
+		Etag: sql.NullString{
+			String: info.Cache.ETag,
+			Valid:  info.Cache.ETag != "",
+		},
+		LastModified: sql.NullString{
+			String: info.Cache.LastModified,
+			Valid:  info.Cache.LastModified != "",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Could not store cache headers: %v", err)
+	}
+
+	for _, item := range feedData.Items {
+		_, err = storePost(ctx, qtx, feedid, item)
+		if err != nil {
+			return fmt.Errorf("Could not store post %s: %v", item.Title, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Could not add feed: %v", err)
+	}
+
 	fmt.Printf("Feed added to Database: %v\n", feed)
+	fmt.Printf("%d posts collected\n", len(feedData.Items))
 	return nil
 }
 
+//fetch the feed behind the url given to addfeed, a website is searched for its
+//feeds and a url that is a feed itself is only requested once
+func fetchNewFeed(ctx context.Context, fetcher *rss.Fetcher, pageURL string) (string, *rss.Feed, rss.ResponseInfo, error) {
+	links, err := fetcher.Discover(ctx, pageURL)
+	if errors.Is(err, rss.ErrNotAFeed) {
+		return "", nil, rss.ResponseInfo{}, fmt.Errorf("No feed found at %s", pageURL)
+	}
+	if err != nil {
+		return "", nil, rss.ResponseInfo{}, fmt.Errorf("Could not fetch %s: %v", pageURL, err)
+	}
+
+	if len(links) > 1 {
+		fmt.Printf("Found %d feeds at %s:\n", len(links), pageURL)
+		for _, link := range links {
+			fmt.Printf("* %s (%s)\n", link.URL, link.Title)
+		}
+		return "", nil, rss.ResponseInfo{}, fmt.Errorf("Several feeds found, run addfeed again with one of them")
+	}
+	link := links[0]
+	if link.Feed != nil {
+		if link.URL != pageURL {
+			fmt.Printf("Found feed at %s\n", link.URL)
+		}
+		return link.URL, link.Feed, link.Info, nil
+	}
+	fmt.Printf("Found feed at %s\n", link.URL)
+
+	feedData, info, err := fetcher.Fetch(ctx, link.URL, rss.CacheHeaders{})
+	if err != nil {
+		return "", nil, info, fmt.Errorf("Could not fetch %s: %v", link.URL, err)
+	}
+	if info.MovedTo != "" {
+		return info.MovedTo, feedData, info, nil
+	}
+	return link.URL, feedData, info, nil
+}
+
 //return feeds in database
-func handlerFeeds(s *state, cmd command) error {
-	feeds, err := s.db.GetFeeds(context.Background())
+func handlerFeeds(ctx context.Context, s *state, cmd command) error {
+	feeds, err := s.db.GetFeeds(ctx)
 	if err != nil {
 		return fmt.Errorf("Failed to get feeds from database: %v", err)
 	}
-	fmt.Printf("%v", feeds)
+	for _, feed := range feeds {
+		fmt.Printf("* %s (%s) added by %s\n", feed.FeedName, feed.Url, feed.UserName)
+		fmt.Printf("  %s\n", feedHealth(feed))
+		fmt.Printf("  %s\n", feedSchedule(feed))
+	}
+	return nil
+}
+
+//describe when the aggregator will fetch a feed next
+func feedSchedule(feed database.GetFeedsRow) string {
+	interval := "adaptive"
+	if feed.FetchIntervalSeconds.Valid {
+		interval = fmt.Sprintf("every %s", time.Duration(feed.FetchIntervalSeconds.Int32)*time.Second)
+	}
+	if feed.Status == feedStatusPaused {
+		return fmt.Sprintf("schedule: %s", interval)
+	}
+	next := "due now"
+	if feed.NextFetchAt.Valid && feed.NextFetchAt.Time.After(time.Now()) {
+		next = "next fetch " + feed.NextFetchAt.Time.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("schedule: %s, %s", interval, next)
+}
+
+//describe how the last fetches of a feed went
+func feedHealth(feed database.GetFeedsRow) string {
+	const layout = "2006-01-02 15:04"
+	switch feed.Status {
+	case feedStatusPaused:
+		return "status: paused"
+	case feedStatusDead:
+		return fmt.Sprintf("status: deactivated after %d failures in a row, last error: %s",
+			feed.ConsecutiveFailures, feed.LastError.String)
+	}
+	if !feed.LastFetchedAt.Valid {
+		return "status: never fetched"
+	}
+	lastSuccess := "never"
+	if feed.LastSuccessAt.Valid {
+		lastSuccess = feed.LastSuccessAt.Time.Format(layout)
+	}
+	if feed.ConsecutiveFailures == 0 {
+		return fmt.Sprintf("status: ok, last success %s", lastSuccess)
+	}
+	return fmt.Sprintf("status: failing, %d failures in a row, last success %s, last error: %s",
+		feed.ConsecutiveFailures, lastSuccess, feed.LastError.String)
+}
+
+//stop the aggregator from fetching a feed until it is resumed
+func handlerPauseFeed(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <url>", cmd.Name)
+	}
+
+	feed, err := s.db.SetFeedStatus(ctx, database.SetFeedStatusParams{
+		Url:    cmd.Args[0],
+		Status: feedStatusPaused,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNotYourFeed(cmd.Args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("Could not pause feed: %v", err)
+	}
+	fmt.Printf("Paused %s\n", feed.Name)
+	return nil
+}
+
+//put a paused or deactivated feed back on the aggregator's schedule
+func handlerResumeFeed(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <url>", cmd.Name)
+	}
+
+	feed, err := s.db.SetFeedStatus(ctx, database.SetFeedStatusParams{
+		Url:    cmd.Args[0],
+		Status: feedStatusActive,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNotYourFeed(cmd.Args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("Could not resume feed: %v", err)
+	}
+	fmt.Printf("Resumed %s\n", feed.Name)
+	return nil
+}
+
+//feeds are shared by their followers, the ones a user neither added nor
+//follows are left alone
+func errNotYourFeed(url string) error {
+	return fmt.Errorf("No feed at %s that you added or follow", url)
+}
+
+//fix how often a feed is fetched, or hand it back to the adaptive schedule with "auto"
+func handlerSetInterval(ctx context.Context, s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: %s <url> <interval|auto>", cmd.Name)
+	}
+
+	interval := sql.NullInt32{}
+	if cmd.Args[1] != "auto" {
+		d, err := time.ParseDuration(cmd.Args[1])
+		if err != nil {
+			return fmt.Errorf("Invalid interval: %v", err)
+		}
+		if d < time.Minute {
+			return fmt.Errorf("Interval must be at least 1m")
+		}
+		interval = sql.NullInt32{
+			Int32: int32(d.Seconds()),
+			Valid: true,
+		}
+	}
+
+	feed, err := s.db.SetFeedFetchInterval(ctx, database.SetFeedFetchIntervalParams{
+		Url:                  cmd.Args[0],
+		FetchIntervalSeconds: interval,
+		UserID:               user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNotYourFeed(cmd.Args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("Could not set interval of feed: %v", err)
+	}
+	if !interval.Valid {
+		fmt.Printf("%s is fetched on the adaptive schedule\n", feed.Name)
+		return nil
+	}
+	fmt.Printf("%s is fetched every %s\n", feed.Name, time.Duration(interval.Int32)*time.Second)
 	return nil
 }
 
 //create an entry in the feed_follow table for the current user given a url
-func handlerFollow(s *state, cmd command, user database.User) error {
+func handlerFollow(ctx context.Context, s *state, cmd command, user database.User) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("usage: %s <url>", cmd.Name)
 	}
 
 	url := cmd.Args[0]
-	feed, err := s.db.GetFeed(context.Background(), url)
+	feed, err := s.db.GetFeed(ctx, url)
 	if err != nil {
 		return fmt.Errorf("Could not retrieve feed: %v", err)
 	}
@@ -243,7 +456,7 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 		FeedID: feedid,
 	}
 
-	_, err = s.db.CreateFeedFollow(context.Background(), follow_args)
+	_, err = s.db.CreateFeedFollow(ctx, follow_args)
 	if err != nil {
 		return fmt.Errorf("Could not create feed_follow: %v", err)
 	}
@@ -252,13 +465,13 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 }
 
 //return the names of followed feeds for the current user
-func handlerFollowing(s *state, cmd command, user database.User) error {
+func handlerFollowing(ctx context.Context, s *state, cmd command, user database.User) error {
 	if len(cmd.Args) != 0 {
 		return fmt.Errorf("usage: %s", cmd.Name)
 	}
 	
 	userid := user.ID
-	followed_feeds, err := s.db.GetFeedFollowsForUser(context.Background(), userid)
+	followed_feeds, err := s.db.GetFeedFollowsForUser(ctx, userid)
 	if err != nil {
 		return fmt.Errorf("Failed to get followed feeds: %v\n", err)
 	}
@@ -270,13 +483,13 @@ func handlerFollowing(s *state, cmd command, user database.User) error {
 }
 
 //unfollow a specified feed for the current user
-func handlerUnfollow(s *state, cmd command, user database.User) error {
+func handlerUnfollow(ctx context.Context, s *state, cmd command, user database.User) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("usage: %s <url>", cmd.Name)
 	}
 	url := cmd.Args[0]
 	userid := user.ID
-	feed, err := s.db.GetFeed(context.Background(), url)
+	feed, err := s.db.GetFeed(ctx, url)
 	if err != nil {
 		return fmt.Errorf("Error retrieving feed: %v\n", err)
 	}
@@ -286,47 +499,16 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 		UserID: userid,
 		FeedID: feedid, 
 	}
-	err = s.db.Unfollow(context.Background(), unfollow_args)
+	err = s.db.Unfollow(ctx, unfollow_args)
 	if err != nil {
 		return fmt.Errorf("Could not unfollow: %v", err)
 	}
 	return nil
 }
 
-//browse through posts saved in the database
-func handlerBrowse(s *state, cmd command, user database.User) error {
-	//limit := 2
-	var limit int32 = 2
-	if len(cmd.Args) != 0 {
-		strVal := cmd.Args[0]
-		num, err := strconv.Atoi(strVal)
-	if err != nil {
-		return fmt.Errorf("invalid number: %v", err)
-	}
-	if num != 0 {
-		limit = int32(num)
-	} 
-	}
-	userid := user.ID
-	GetPostsForUserParams := database.GetPostsForUserParams{
-		UserID: userid,
-		Limit: limit,
-	}
-
-	posts, err := s.db.GetPostsForUser(context.Background(), GetPostsForUserParams)
-	if err != nil {
-		return fmt.Errorf("Error retrieving posts: %v\n", err)
-	}
-
-	for _, post := range posts {
-		fmt.Printf("%v", post)
-	}
-	return nil
-}
-
 //reset the database
-func handlerReset(s *state, cmd command) error {
-	err := s.db.DeleteUsers(context.Background())
+func handlerReset(ctx context.Context, s *state, cmd command) error {
+	err := s.db.DeleteUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("Failed to remove users: %v\n", err)
 	}
@@ -11,6 +11,35 @@ const configFileName = ".gatorconfig.json"
 type Config struct {
 	DBURL           string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	//consecutive failures before agg deactivates a feed, 0 uses the default
+	DeadFeedFailures int `json:"dead_feed_failures,omitempty"`
+	//how feeds are requested, left out everything takes its default
+	Fetch *FetchConfig `json:"fetch,omitempty"`
+}
+
+//durations are Go duration strings such as "20s"
+type FetchConfig struct {
+	Timeout      string `json:"timeout,omitempty"`
+	MaxBodyBytes int64  `json:"max_body_bytes,omitempty"`
+	UserAgent    string `json:"user_agent,omitempty"`
+	//proxy url, empty uses HTTP_PROXY and HTTPS_PROXY, "direct" uses none
+	Proxy   string            `json:"proxy,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	//politeness towards a single host
+	HostConcurrency int    `json:"host_concurrency,omitempty"`
+	HostInterval    string `json:"host_interval,omitempty"`
+
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+type TLSConfig struct {
+	//pem bundle trusted on top of the system roots
+	CAFile string `json:"ca_file,omitempty"`
+	//client certificate for proxies or hosts that ask for one
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	MinVersion         string `json:"min_version,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
 }
 
 func (cfg *Config) SetUser(userName string) error {
@@ -7,31 +7,103 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimNextFeedsToFetch = `-- name: ClaimNextFeedsToFetch :many
+WITH claimed AS (
+    SELECT id FROM feeds
+    WHERE status IN ('active', 'dead')
+    AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    AND (locked_until IS NULL OR locked_until < NOW())
+    ORDER BY next_fetch_at ASC NULLS FIRST
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE feeds
+SET locked_until = NOW() + $2::int * INTERVAL '1 second'
+FROM claimed
+WHERE feeds.id = claimed.id
+RETURNING feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.etag, feeds.last_modified, feeds.locked_until, feeds.consecutive_failures, feeds.last_error, feeds.last_success_at, feeds.status, feeds.fetch_interval_seconds, feeds.next_fetch_at, feeds.hint_interval_seconds, feeds.skip_hours, feeds.skip_days, feeds.site_url, feeds.description
+`
+
+type ClaimNextFeedsToFetchParams struct {
+	MaxFeeds     int32
+	LeaseSeconds int32
+}
+
+func (q *Queries) ClaimNextFeedsToFetch(ctx context.Context, arg ClaimNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimNextFeedsToFetch, arg.MaxFeeds, arg.LeaseSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LockedUntil,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastSuccessAt,
+			&i.Status,
+			&i.FetchIntervalSeconds,
+			&i.NextFetchAt,
+			&i.HintIntervalSeconds,
+			&i.SkipHours,
+			&i.SkipDays,
+			&i.SiteUrl,
+			&i.Description,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, site_url, description)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7,
+    $8
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, locked_until, consecutive_failures, last_error, last_success_at, status, fetch_interval_seconds, next_fetch_at, hint_interval_seconds, skip_hours, skip_days, site_url, description
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string
+	Url         string
+	UserID      uuid.UUID
+	SiteUrl     sql.NullString
+	Description sql.NullString
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -42,6 +114,8 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.SiteUrl,
+		arg.Description,
 	)
 	var i Feed
 	err := row.Scan(
@@ -52,12 +126,36 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LockedUntil,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.Status,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.HintIntervalSeconds,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.SiteUrl,
+		&i.Description,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
 const getFeed = `-- name: GetFeed :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, locked_until, consecutive_failures, last_error, last_success_at, status, fetch_interval_seconds, next_fetch_at, hint_interval_seconds, skip_hours, skip_days, site_url, description FROM feeds
 WHERE url = $1
 `
 
@@ -72,6 +170,20 @@ func (q *Queries) GetFeed(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LockedUntil,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.Status,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.HintIntervalSeconds,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.SiteUrl,
+		&i.Description,
 	)
 	return i, err
 }
@@ -81,7 +193,14 @@ SELECT
     feeds.name AS feed_name,
     feeds.url,
     feeds.user_id,
-    users.name AS user_name
+    users.name AS user_name,
+    feeds.last_fetched_at,
+    feeds.consecutive_failures,
+    feeds.last_error,
+    feeds.last_success_at,
+    feeds.status,
+    feeds.fetch_interval_seconds,
+    feeds.next_fetch_at
 FROM 
     feeds
 JOIN 
@@ -89,10 +208,17 @@ JOIN
 `
 
 type GetFeedsRow struct {
-	FeedName string
-	Url      string
-	UserID   uuid.UUID
-	UserName string
+	FeedName             string
+	Url                  string
+	UserID               uuid.UUID
+	UserName             string
+	LastFetchedAt        sql.NullTime
+	ConsecutiveFailures  int32
+	LastError            sql.NullString
+	LastSuccessAt        sql.NullTime
+	Status               string
+	FetchIntervalSeconds sql.NullInt32
+	NextFetchAt          sql.NullTime
 }
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
@@ -109,6 +235,13 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 			&i.Url,
 			&i.UserID,
 			&i.UserName,
+			&i.LastFetchedAt,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastSuccessAt,
+			&i.Status,
+			&i.FetchIntervalSeconds,
+			&i.NextFetchAt,
 		); err != nil {
 			return nil, err
 		}
@@ -123,14 +256,23 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1
+const markFeedFetched = `-- name: MarkFeedFetched :one
+UPDATE feeds
+SET last_fetched_at = NOW(),
+next_fetch_at = NOW() + $1::int * INTERVAL '1 second',
+locked_until = NULL,
+updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, locked_until, consecutive_failures, last_error, last_success_at, status, fetch_interval_seconds, next_fetch_at, hint_interval_seconds, skip_hours, skip_days, site_url, description
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+type MarkFeedFetchedParams struct {
+	DelaySeconds int32
+	ID           uuid.UUID
+}
+
+func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, markFeedFetched, arg.DelaySeconds, arg.ID)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -140,20 +282,204 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LockedUntil,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.Status,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.HintIntervalSeconds,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.SiteUrl,
+		&i.Description,
 	)
 	return i, err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :one
+const recordFeedFailure = `-- name: RecordFeedFailure :one
 UPDATE feeds
-SET last_fetched_at = NOW(),
+SET consecutive_failures = consecutive_failures + 1,
+last_error = $1,
+status = CASE
+    WHEN status = 'active' AND consecutive_failures + 1 >= $2::int THEN 'dead'
+    ELSE status
+END
+WHERE id = $3
+RETURNING consecutive_failures, status
+`
+
+type RecordFeedFailureParams struct {
+	LastError     sql.NullString
+	DeadThreshold int32
+	ID            uuid.UUID
+}
+
+type RecordFeedFailureRow struct {
+	ConsecutiveFailures int32
+	Status              string
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (RecordFeedFailureRow, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure, arg.LastError, arg.DeadThreshold, arg.ID)
+	var i RecordFeedFailureRow
+	err := row.Scan(&i.ConsecutiveFailures, &i.Status)
+	return i, err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :one
+UPDATE feeds
+SET consecutive_failures = 0,
+last_error = NULL,
+last_success_at = NOW(),
+status = CASE WHEN status = 'dead' THEN 'active' ELSE status END
+WHERE id = $1
+RETURNING status
+`
+
+func (q *Queries) RecordFeedSuccess(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedSuccess, id)
+	var status string
+	err := row.Scan(&status)
+	return status, err
+}
+
+const releaseFeedLease = `-- name: ReleaseFeedLease :exec
+UPDATE feeds
+SET locked_until = NULL
+WHERE id = $1
+`
+
+func (q *Queries) ReleaseFeedLease(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedLease, id)
+	return err
+}
+
+const setFeedCacheHeaders = `-- name: SetFeedCacheHeaders :exec
+UPDATE feeds
+SET etag = $2,
+last_modified = $3,
+updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedCacheHeadersParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) SetFeedCacheHeaders(ctx context.Context, arg SetFeedCacheHeadersParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedCacheHeaders, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const setFeedFetchInterval = `-- name: SetFeedFetchInterval :one
+UPDATE feeds
+SET fetch_interval_seconds = $2,
+next_fetch_at = NULL,
 updated_at = NOW()
+WHERE url = $1
+-- feeds are shared, only the user who added a feed or one following it may change it
+AND (user_id = $3 OR EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = $3
+))
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, locked_until, consecutive_failures, last_error, last_success_at, status, fetch_interval_seconds, next_fetch_at, hint_interval_seconds, skip_hours, skip_days, site_url, description
+`
+
+type SetFeedFetchIntervalParams struct {
+	Url                  string
+	FetchIntervalSeconds sql.NullInt32
+	UserID               uuid.UUID
+}
+
+func (q *Queries) SetFeedFetchInterval(ctx context.Context, arg SetFeedFetchIntervalParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFetchInterval, arg.Url, arg.FetchIntervalSeconds, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LockedUntil,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.Status,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.HintIntervalSeconds,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.SiteUrl,
+		&i.Description,
+	)
+	return i, err
+}
+
+const setFeedScheduleHints = `-- name: SetFeedScheduleHints :exec
+UPDATE feeds
+SET hint_interval_seconds = $2,
+skip_hours = $3,
+skip_days = $4
 WHERE id = $1
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
 `
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, markFeedFetched, id)
+type SetFeedScheduleHintsParams struct {
+	ID                  uuid.UUID
+	HintIntervalSeconds int32
+	SkipHours           int32
+	SkipDays            int32
+	SiteUrl             sql.NullString
+	Description         sql.NullString
+}
+
+func (q *Queries) SetFeedScheduleHints(ctx context.Context, arg SetFeedScheduleHintsParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedScheduleHints,
+		arg.ID,
+		arg.HintIntervalSeconds,
+		arg.SkipHours,
+		arg.SkipDays,
+		arg.SiteUrl,
+		arg.Description,
+	)
+	return err
+}
+
+const setFeedStatus = `-- name: SetFeedStatus :one
+UPDATE feeds
+SET status = $2,
+consecutive_failures = CASE WHEN $2 = 'active' THEN 0 ELSE consecutive_failures END,
+next_fetch_at = CASE WHEN $2 = 'active' THEN NULL ELSE next_fetch_at END,
+updated_at = NOW()
+WHERE url = $1
+-- feeds are shared, only the user who added a feed or one following it may change it
+AND (user_id = $3 OR EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = $3
+))
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, locked_until, consecutive_failures, last_error, last_success_at, status, fetch_interval_seconds, next_fetch_at, hint_interval_seconds, skip_hours, skip_days, site_url, description
+`
+
+type SetFeedStatusParams struct {
+	Url    string
+	Status string
+	UserID uuid.UUID
+}
+
+func (q *Queries) SetFeedStatus(ctx context.Context, arg SetFeedStatusParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedStatus, arg.Url, arg.Status, arg.UserID)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -163,6 +489,36 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, erro
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LockedUntil,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.Status,
+		&i.FetchIntervalSeconds,
+		&i.NextFetchAt,
+		&i.HintIntervalSeconds,
+		&i.SkipHours,
+		&i.SkipDays,
+		&i.SiteUrl,
+		&i.Description,
 	)
 	return i, err
 }
+
+const setFeedURL = `-- name: SetFeedURL :exec
+UPDATE feeds
+SET url = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedURLParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) SetFeedURL(ctx context.Context, arg SetFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedURL, arg.ID, arg.Url)
+	return err
+}
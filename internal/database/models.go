@@ -11,14 +11,38 @@ import (
 	"github.com/google/uuid"
 )
 
+type Author struct {
+	ID   uuid.UUID
+	Name string
+}
+
+type Category struct {
+	ID   uuid.UUID
+	Name string
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 string
+	Url                  string
+	UserID               uuid.UUID
+	LastFetchedAt        sql.NullTime
+	Etag                 sql.NullString
+	LastModified         sql.NullString
+	LockedUntil          sql.NullTime
+	ConsecutiveFailures  int32
+	LastError            sql.NullString
+	LastSuccessAt        sql.NullTime
+	Status               string
+	FetchIntervalSeconds sql.NullInt32
+	NextFetchAt          sql.NullTime
+	HintIntervalSeconds  int32
+	SkipHours            int32
+	SkipDays             int32
+	SiteUrl              sql.NullString
+	Description          sql.NullString
 }
 
 type FeedFollow struct {
@@ -38,6 +62,41 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Guid        string
+	ContentHash sql.NullString
+	Content     sql.NullString
+}
+
+type PostAuthor struct {
+	PostID   uuid.UUID
+	AuthorID uuid.UUID
+}
+
+type PostCategory struct {
+	PostID     uuid.UUID
+	CategoryID uuid.UUID
+}
+
+type PostEnclosure struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+	Thumbnail       bool
+}
+
+type PostRevision struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	PostID      uuid.UUID
+	Title       string
+	Url         string
+	Description sql.NullString
+	RevisedAt   time.Time
+	Content     sql.NullString
 }
 
 type User struct {
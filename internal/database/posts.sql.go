@@ -13,8 +13,83 @@ import (
 	"github.com/google/uuid"
 )
 
+const adoptLegacyPost = `-- name: AdoptLegacyPost :exec
+UPDATE posts
+SET guid = $1
+WHERE feed_id = $2
+AND url = $3
+AND content_hash IS NULL
+AND guid <> $1
+AND NOT EXISTS (
+    SELECT 1 FROM posts AS adopted
+    WHERE adopted.feed_id = $2
+    AND adopted.guid = $1
+)
+`
+
+type AdoptLegacyPostParams struct {
+	Guid   string
+	FeedID uuid.UUID
+	Url    string
+}
+
+func (q *Queries) AdoptLegacyPost(ctx context.Context, arg AdoptLegacyPostParams) error {
+	_, err := q.db.ExecContext(ctx, adoptLegacyPost, arg.Guid, arg.FeedID, arg.Url)
+	return err
+}
+
+const backfillPostHash = `-- name: BackfillPostHash :one
+UPDATE posts
+SET title = $1,
+url = $2,
+description = $3,
+content = $4,
+content_hash = $5
+WHERE feed_id = $6
+AND guid = $7
+AND content_hash IS NULL
+RETURNING id
+`
+
+type BackfillPostHashParams struct {
+	Title       string
+	Url         string
+	Description sql.NullString
+	Content     sql.NullString
+	ContentHash sql.NullString
+	FeedID      uuid.UUID
+	Guid        string
+}
+
+// posts stored before content hashes were kept take the current version as
+// they are, nothing says it changed so no revision is kept and updated_at stays
+func (q *Queries) BackfillPostHash(ctx context.Context, arg BackfillPostHashParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, backfillPostHash,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.Content,
+		arg.ContentHash,
+		arg.FeedID,
+		arg.Guid,
+	)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+WITH revision AS (
+    -- keep the stored version around before it is overwritten, in the same
+    -- statement so a failed upsert leaves no revision behind
+    INSERT INTO post_revisions (id, created_at, post_id, title, url, description, revised_at, content)
+    SELECT gen_random_uuid(), NOW(), posts.id, posts.title, posts.url, posts.description, posts.updated_at, posts.content
+    FROM posts
+    WHERE posts.feed_id = $8
+    AND posts.guid = $9
+    AND posts.content_hash IS DISTINCT FROM $10
+)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, content)
 VALUES (
     $1,
     $2,
@@ -23,9 +98,20 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+url = EXCLUDED.url,
+description = EXCLUDED.description,
+content = EXCLUDED.content,
+content_hash = EXCLUDED.content_hash,
+updated_at = EXCLUDED.updated_at
+WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, content
 `
 
 type CreatePostParams struct {
@@ -37,6 +123,9 @@ type CreatePostParams struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Guid        string
+	ContentHash sql.NullString
+	Content     sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -49,6 +138,9 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
+		arg.Content,
 	)
 	var i Post
 	err := row.Scan(
@@ -60,22 +152,39 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.Content,
 	)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name AS feed_name FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.guid, posts.content_hash, posts.content, feeds.name AS feed_name FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 JOIN feeds ON posts.feed_id = feeds.id
 WHERE feed_follows.user_id = $1
+AND ($2::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_authors
+    JOIN authors ON authors.id = post_authors.author_id
+    WHERE post_authors.post_id = posts.id
+    AND strpos(lower(authors.name), lower($2)) > 0
+))
+AND ($3::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_categories
+    JOIN categories ON categories.id = post_categories.category_id
+    WHERE post_categories.post_id = posts.id
+    AND lower(categories.name) = lower($3)
+))
 ORDER BY posts.published_at DESC
-LIMIT $2
+LIMIT $4
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID   uuid.UUID
+	Author   sql.NullString
+	Category sql.NullString
+	MaxPosts int32
 }
 
 type GetPostsForUserRow struct {
@@ -87,11 +196,19 @@ type GetPostsForUserRow struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Guid        string
+	ContentHash sql.NullString
+	Content     sql.NullString
 	FeedName    string
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.Author,
+		arg.Category,
+		arg.MaxPosts,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -108,6 +225,9 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Guid,
+			&i.ContentHash,
+			&i.Content,
 			&i.FeedName,
 		); err != nil {
 			return nil, err
@@ -122,3 +242,58 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getRecentPublishTimes = `-- name: GetRecentPublishTimes :many
+SELECT published_at FROM posts
+WHERE feed_id = $1
+AND published_at IS NOT NULL
+ORDER BY published_at DESC
+LIMIT $2
+`
+
+type GetRecentPublishTimesParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentPublishTimes(ctx context.Context, arg GetRecentPublishTimesParams) ([]sql.NullTime, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPublishTimes, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullTime
+	for rows.Next() {
+		var published_at sql.NullTime
+		if err := rows.Scan(&published_at); err != nil {
+			return nil, err
+		}
+		items = append(items, published_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePosts = `-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = $1
+WHERE feed_id = $2
+AND guid NOT IN (
+    SELECT guid FROM posts WHERE feed_id = $1
+)
+`
+
+type MovePostsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
@@ -0,0 +1,445 @@
+package htmltext
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+//elements that break the text into separate blocks
+var blockElements = map[string]bool{
+	"address":    true,
+	"article":    true,
+	"aside":      true,
+	"blockquote": true,
+	"caption":    true,
+	"dd":         true,
+	"div":        true,
+	"dl":         true,
+	"dt":         true,
+	"figcaption": true,
+	"figure":     true,
+	"footer":     true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"header":     true,
+	"hr":         true,
+	"li":         true,
+	"main":       true,
+	"nav":        true,
+	"ol":         true,
+	"p":          true,
+	"pre":        true,
+	"section":    true,
+	"table":      true,
+	"tr":         true,
+	"ul":         true,
+}
+
+//blocks set apart from their neighbours by a blank line
+var spacedElements = map[string]bool{
+	"blockquote": true,
+	"dl":         true,
+	"figure":     true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"hr":         true,
+	"p":          true,
+	"pre":        true,
+	"table":      true,
+}
+
+//how inline elements are marked in plain text
+var emphasis = map[string]string{
+	"b":      "*",
+	"strong": "*",
+	"em":     "_",
+	"i":      "_",
+	"code":   "`",
+	"kbd":    "`",
+	"samp":   "`",
+	"q":      `"`,
+}
+
+type list struct {
+	ordered bool
+	next    int
+	//an item is open, html lets the next <li> close it
+	inItem bool
+}
+
+type renderer struct {
+	width int
+	out   strings.Builder
+	//inline text of the block being built
+	line strings.Builder
+	//what starts each line of the current block, "> " per quote and spaces
+	//per list item
+	indent []string
+	//bullet or number of a list item whose first line is not written yet,
+	//and how deep the indent was when the item started
+	marker      string
+	markerDepth int
+	//a blank line goes before the next block, drawn with the indent of the
+	//blocks it separates
+	blank       bool
+	blankPrefix string
+	lists       []list
+	pre         int
+	links       []string
+	linkNumbers map[string]int
+	//href and text offset of the links we are inside of
+	anchors []anchor
+	dropped []string
+}
+
+type anchor struct {
+	href  string
+	start int
+}
+
+//turn an html fragment into plain text for a terminal, wrapped to width
+//columns (no wrapping when width is 0), lists get bullets or numbers,
+//emphasis is marked with * and _, and the targets of links and images are
+//numbered footnotes listed at the end
+func Render(s string, width int) string {
+	r := &renderer{
+		width:       width,
+		linkNumbers: map[string]int{},
+	}
+	for _, tok := range tokenize(s) {
+		switch tok.Type {
+		case html.TextToken:
+			if len(r.dropped) == 0 {
+				r.text(stripControl(tok.Data))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedElements[tok.Data] {
+				if !voidElements[tok.Data] && tok.Type != html.SelfClosingTagToken {
+					r.dropped = append(r.dropped, tok.Data)
+				}
+				continue
+			}
+			if len(r.dropped) == 0 {
+				r.start(tok)
+			}
+		case html.EndTagToken:
+			if len(r.dropped) > 0 {
+				if r.dropped[len(r.dropped)-1] == tok.Data {
+					r.dropped = r.dropped[:len(r.dropped)-1]
+				}
+				continue
+			}
+			r.end(tok.Data)
+		}
+	}
+	r.flush()
+
+	text := strings.TrimRight(r.out.String(), "\n")
+	if len(r.links) == 0 {
+		return stripControl(text)
+	}
+	var b strings.Builder
+	b.WriteString(text)
+	b.WriteString("\n")
+	for i, link := range r.links {
+		fmt.Fprintf(&b, "\n[%d] %s", i+1, link)
+	}
+	//attribute values reach the output too, none of it may drive the terminal
+	return stripControl(strings.TrimLeft(b.String(), "\n"))
+}
+
+//make a string from a feed safe to print on one terminal line, control
+//characters go and runs of whitespace, line breaks included, become a space
+func CleanText(s string) string {
+	return strings.Join(strings.FieldsFunc(stripControl(s), isSpaceRune), " ")
+}
+
+func (r *renderer) text(s string) {
+	if r.pre > 0 {
+		r.line.WriteString(s)
+		return
+	}
+	//collapse runs of whitespace, also across text pieces
+	if s != "" && isSpaceRune(rune(s[0])) {
+		r.space()
+	}
+	words := strings.FieldsFunc(s, isSpaceRune)
+	for i, word := range words {
+		if i > 0 {
+			r.space()
+		}
+		r.line.WriteString(word)
+	}
+	if len(words) > 0 && isSpaceRune(rune(s[len(s)-1])) {
+		r.space()
+	}
+}
+
+//a single space unless the line is empty or already ends in whitespace
+func (r *renderer) space() {
+	current := r.line.String()
+	if current == "" || strings.HasSuffix(current, " ") || strings.HasSuffix(current, "\n") {
+		return
+	}
+	r.line.WriteString(" ")
+}
+
+func (r *renderer) start(tok html.Token) {
+	name := tok.Data
+	if blockElements[name] {
+		r.flush()
+		//nested lists belong to their item and stay close to it
+		if spacedElements[name] || (name == "ul" || name == "ol") && len(r.lists) == 0 {
+			r.separate()
+		}
+	}
+	switch name {
+	case "br":
+		r.trimSpace()
+		r.line.WriteString("\n")
+	case "hr":
+		r.line.WriteString("---")
+		r.flush()
+		r.separate()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.line.WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+	case "blockquote":
+		r.indent = append(r.indent, "> ")
+	case "pre":
+		r.pre++
+	case "ul", "ol":
+		l := list{ordered: name == "ol", next: 1}
+		if start := attr(tok, "start"); start != "" {
+			fmt.Sscanf(start, "%d", &l.next)
+		}
+		r.lists = append(r.lists, l)
+	case "li":
+		marker := "- "
+		if len(r.lists) > 0 {
+			if r.lists[len(r.lists)-1].inItem {
+				r.end("li")
+			}
+			l := &r.lists[len(r.lists)-1]
+			l.inItem = true
+			if l.ordered {
+				marker = fmt.Sprintf("%d. ", l.next)
+				l.next++
+			}
+		}
+		r.marker = marker
+		r.indent = append(r.indent, strings.Repeat(" ", len(marker)))
+		r.markerDepth = len(r.indent)
+	case "td", "th":
+		if strings.TrimSpace(r.line.String()) != "" {
+			r.trimSpace()
+			r.line.WriteString(" | ")
+		}
+	case "a":
+		r.anchors = append(r.anchors, anchor{href: strings.TrimSpace(attr(tok, "href")), start: r.line.Len()})
+	case "img":
+		alt := strings.Join(strings.Fields(attr(tok, "alt")), " ")
+		label := "[image]"
+		if alt != "" {
+			label = "[image: " + alt + "]"
+		}
+		if src := strings.TrimSpace(attr(tok, "src")); src != "" && SafeURL(src) {
+			label += r.footnote(src)
+		}
+		r.space()
+		r.line.WriteString(label)
+	default:
+		if mark, ok := emphasis[name]; ok && (r.pre == 0 || mark != "`") {
+			r.line.WriteString(mark)
+		}
+	}
+}
+
+func (r *renderer) end(name string) {
+	switch name {
+	case "blockquote":
+		r.flush()
+		r.popIndent(true)
+	case "li":
+		r.flush()
+		r.popIndent(false)
+		r.marker = ""
+		if len(r.lists) > 0 {
+			r.lists[len(r.lists)-1].inItem = false
+		}
+	case "pre":
+		r.flush()
+		if r.pre > 0 {
+			r.pre--
+		}
+	case "ul", "ol":
+		if len(r.lists) > 0 && r.lists[len(r.lists)-1].inItem {
+			r.end("li")
+		}
+		r.flush()
+		if len(r.lists) > 0 {
+			r.lists = r.lists[:len(r.lists)-1]
+		}
+		if len(r.lists) == 0 {
+			r.separate()
+		}
+	case "a":
+		if len(r.anchors) == 0 {
+			break
+		}
+		a := r.anchors[len(r.anchors)-1]
+		r.anchors = r.anchors[:len(r.anchors)-1]
+		if a.href == "" || strings.HasPrefix(a.href, "#") || !SafeURL(a.href) {
+			break
+		}
+		label := ""
+		if a.start <= r.line.Len() {
+			label = strings.TrimSpace(r.line.String()[a.start:])
+		}
+		//a bare url already says where it goes
+		if label == a.href || "mailto:"+label == a.href {
+			break
+		}
+		r.trimSpace()
+		r.line.WriteString(r.footnote(a.href))
+	default:
+		if mark, ok := emphasis[name]; ok && (r.pre == 0 || mark != "`") {
+			r.trimSpace()
+			r.line.WriteString(mark)
+		}
+	}
+	if blockElements[name] {
+		r.flush()
+		if spacedElements[name] {
+			r.separate()
+		}
+	}
+}
+
+//ask for a blank line before the next block, leaving a quote the line is
+//drawn with the outer indent
+func (r *renderer) separate() {
+	prefix := strings.TrimRight(strings.Join(r.indent, ""), " ")
+	if !r.blank || len(prefix) < len(r.blankPrefix) {
+		r.blankPrefix = prefix
+	}
+	r.blank = true
+}
+
+//drop the innermost indent of a quote or of a list item
+func (r *renderer) popIndent(quote bool) {
+	for i := len(r.indent) - 1; i >= 0; i-- {
+		if (r.indent[i] == "> ") == quote {
+			r.indent = append(r.indent[:i], r.indent[i+1:]...)
+			return
+		}
+	}
+}
+
+//number a link target, the same target keeps its number
+func (r *renderer) footnote(href string) string {
+	n, ok := r.linkNumbers[href]
+	if !ok {
+		r.links = append(r.links, href)
+		n = len(r.links)
+		r.linkNumbers[href] = n
+	}
+	return fmt.Sprintf("[%d]", n)
+}
+
+func (r *renderer) trimSpace() {
+	if r.pre > 0 {
+		return
+	}
+	current := r.line.String()
+	if trimmed := strings.TrimRight(current, " "); trimmed != current {
+		r.line.Reset()
+		r.line.WriteString(trimmed)
+	}
+}
+
+//write out the current block
+func (r *renderer) flush() {
+	content := r.line.String()
+	r.line.Reset()
+	if r.pre == 0 {
+		content = strings.TrimSpace(content)
+	} else {
+		content = strings.Trim(content, "\n")
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	if r.blank && r.out.Len() > 0 {
+		r.out.WriteString(r.blankPrefix + "\n")
+	}
+	r.blank = false
+
+	prefix := strings.Join(r.indent, "")
+	first := prefix
+	if r.marker != "" {
+		//the bullet takes the place of the item's indent
+		item := strings.Join(r.indent[:r.markerDepth], "")
+		if r.markerDepth == len(r.indent) {
+			first = item[:len(item)-len(r.marker)] + r.marker
+		} else {
+			r.out.WriteString(strings.TrimRight(item[:len(item)-len(r.marker)]+r.marker, " ") + "\n")
+		}
+		r.marker = ""
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		if r.pre == 0 {
+			line = strings.TrimSpace(line)
+		}
+		for j, wrapped := range r.wrap(line, utf8.RuneCountInString(prefix)) {
+			if i == 0 && j == 0 {
+				r.out.WriteString(strings.TrimRight(first+wrapped, " ") + "\n")
+				continue
+			}
+			r.out.WriteString(strings.TrimRight(prefix+wrapped, " ") + "\n")
+		}
+	}
+}
+
+//break a line into pieces that fit the width after the indent, words longer
+//than a line are left whole, preformatted text is never wrapped
+func (r *renderer) wrap(line string, indent int) []string {
+	width := r.width - indent
+	if r.width <= 0 || r.pre > 0 || utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+	if width < 20 {
+		width = 20
+	}
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(line) {
+		if current != "" && utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, current)
+			current = ""
+		}
+		if current != "" {
+			current += " "
+		}
+		current += word
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+func isSpaceRune(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
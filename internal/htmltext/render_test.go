@@ -0,0 +1,165 @@
+package htmltext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		width int
+		want  string
+	}{
+		{
+			name: "paragraphs and emphasis",
+			in:   `<p>First paragraph.</p><p>Second <b>bold</b>, <i>italic</i>, <code>code()</code> and <q>quote</q>.</p>`,
+			want: "First paragraph.\n" +
+				"\n" +
+				"Second *bold*, _italic_, `code()` and \"quote\".",
+		},
+		{
+			name: "plain text",
+			in:   "  just   some\n text &amp; more  ",
+			want: "just some text & more",
+		},
+		{
+			name: "lists",
+			in:   `<p>Intro</p><ul><li>one</li><li>two<ul><li>nested</li></ul></li><li>three</li></ul><ol start="9"><li>nine</li><li>ten</li></ol><p>After</p>`,
+			want: "Intro\n" +
+				"\n" +
+				"- one\n" +
+				"- two\n" +
+				"  - nested\n" +
+				"- three\n" +
+				"\n" +
+				"9. nine\n" +
+				"10. ten\n" +
+				"\n" +
+				"After",
+		},
+		{
+			name: "unclosed list items",
+			in:   `<ol><li>one<li>two<li>three</ol>`,
+			want: "1. one\n" +
+				"2. two\n" +
+				"3. three",
+		},
+		{
+			name: "footnotes",
+			in:   `<p>See <a href="https://example.com/a">the post</a>, <a href="https://example.com/a">again</a>, <a href="https://example.com/b">https://example.com/b</a>, <a href="#top">top</a> and <a href="javascript:alert(1)">this</a>.</p><p><img src="https://example.com/cat.png" alt="a cat"> <img src="https://example.com/dog.png"></p>`,
+			want: "See the post[1], again[1], https://example.com/b, top and this.\n" +
+				"\n" +
+				"[image: a cat][2] [image][3]\n" +
+				"\n" +
+				"[1] https://example.com/a\n" +
+				"[2] https://example.com/cat.png\n" +
+				"[3] https://example.com/dog.png",
+		},
+		{
+			name:  "wrapping",
+			width: 30,
+			in:    `<p>The quick brown fox jumps over the lazy dog and keeps running far beyond the edge of the line.</p><ul><li>a list item long enough that it has to wrap onto a second line</li></ul><blockquote><p>quoted text that is also long enough to wrap at thirty</p><p>more</p></blockquote><p>after</p>`,
+			want: "The quick brown fox jumps over\n" +
+				"the lazy dog and keeps running\n" +
+				"far beyond the edge of the\n" +
+				"line.\n" +
+				"\n" +
+				"- a list item long enough that\n" +
+				"  it has to wrap onto a second\n" +
+				"  line\n" +
+				"\n" +
+				"> quoted text that is also\n" +
+				"> long enough to wrap at\n" +
+				"> thirty\n" +
+				">\n" +
+				"> more\n" +
+				"\n" +
+				"after",
+		},
+		{
+			name:  "long words are not split",
+			width: 20,
+			in:    `<p>short https://example.com/a/very/long/path/that/does/not/fit end</p>`,
+			want: "short\n" +
+				"https://example.com/a/very/long/path/that/does/not/fit\n" +
+				"end",
+		},
+		{
+			name:  "no wrapping at zero width",
+			width: 0,
+			in:    `<p>` + strings.Repeat("word ", 40) + `</p>`,
+			want:  strings.TrimSpace(strings.Repeat("word ", 40)),
+		},
+		{
+			name:  "preformatted text is kept",
+			width: 10,
+			in:    "<p>code:</p><pre>  keep   this\n    <b>indent</b> and a very long line</pre>",
+			want: "code:\n" +
+				"\n" +
+				"  keep   this\n" +
+				"    *indent* and a very long line",
+		},
+		{
+			name: "headings, breaks, rules and tables",
+			in:   `<h2>Title</h2><p>a<br>b</p><hr><table><tr><th>k</th><th>v</th></tr><tr><td>a</td><td>1</td></tr></table>`,
+			want: "## Title\n" +
+				"\n" +
+				"a\n" +
+				"b\n" +
+				"\n" +
+				"---\n" +
+				"\n" +
+				"k | v\n" +
+				"a | 1",
+		},
+		{
+			name: "dropped elements",
+			in:   `<p>a<script>alert(1)</script><style>p{}</style><svg><text>svg</text></svg>b</p>`,
+			want: "ab",
+		},
+		{
+			name: "escape sequences",
+			in:   "<p>red \x1b[31mtext\u009b2J</p><a href=\"https://example.com/\x1b[2J\">x</a> <img alt=\"\x1b]0;t\x07\" src=\"a.png\">",
+			want: "red [31mtext2J\n" +
+				"\n" +
+				"x[1] [image: ]0;t][2]\n" +
+				"\n" +
+				"[1] https://example.com/[2J\n" +
+				"[2] a.png",
+		},
+		{
+			name: "empty",
+			in:   "<p> </p><div></div>",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in, tt.width); got != tt.want {
+				t.Errorf("Render(%q, %d)\n got:\n%s\nwant:\n%s", tt.in, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Plain title", "Plain title"},
+		{"  spaced \t out\n title ", "spaced out title"},
+		{"evil\x1b[2J\x1b]0;pwned\x07 title", "evil[2J]0;pwned title"},
+		{"csi\u009b31m", "csi31m"},
+		{"line\r\nbreak", "line break"},
+		{"null\x00byte", "nullbyte"},
+		{"unicode ✓ café", "unicode ✓ café"},
+	}
+	for _, tt := range tests {
+		if got := CleanText(tt.in); got != tt.want {
+			t.Errorf("CleanText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
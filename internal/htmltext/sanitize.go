@@ -0,0 +1,272 @@
+package htmltext
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+//elements kept by Sanitize and the attributes each may carry
+var allowedElements = map[string][]string{
+	"a":          {"href", "title"},
+	"abbr":       {"title"},
+	"b":          nil,
+	"blockquote": {"cite"},
+	"br":         nil,
+	"caption":    nil,
+	"cite":       nil,
+	"code":       nil,
+	"dd":         nil,
+	"del":        nil,
+	"div":        nil,
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"figcaption": nil,
+	"figure":     nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"hr":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "title", "width", "height"},
+	"ins":        nil,
+	"kbd":        nil,
+	"li":         nil,
+	"mark":       nil,
+	"ol":         {"start"},
+	"p":          nil,
+	"pre":        nil,
+	"q":          {"cite"},
+	"s":          nil,
+	"samp":       nil,
+	"small":      nil,
+	"span":       nil,
+	"strong":     nil,
+	"sub":        nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"colspan", "rowspan"},
+	"tfoot":      nil,
+	"th":         {"colspan", "rowspan"},
+	"thead":      nil,
+	"time":       {"datetime"},
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+}
+
+//elements dropped along with everything inside them, other unknown elements
+//are unwrapped and their content kept
+var droppedElements = map[string]bool{
+	"applet":   true,
+	"audio":    true,
+	"base":     true,
+	"button":   true,
+	"embed":    true,
+	"form":     true,
+	"frame":    true,
+	"frameset": true,
+	"head":     true,
+	"iframe":   true,
+	"input":    true,
+	"link":     true,
+	"math":     true,
+	"meta":     true,
+	"noembed":  true,
+	"noframes": true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+	"video":    true,
+	"xmp":      true,
+}
+
+//elements that have no content and no end tag
+var voidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"link":   true,
+	"meta":   true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
+
+//attributes holding a url, only web and mail links survive in them
+var urlAttributes = map[string]bool{
+	"href": true,
+	"src":  true,
+	"cite": true,
+}
+
+//strip an html fragment down to markup that is safe to store and show, scripts,
+//styles, embedded objects, forms, event handlers and script urls all go, the
+//result is well formed with every element closed
+func Sanitize(s string) string {
+	var b strings.Builder
+	var open []string
+	//dropped elements we are inside of, nothing is written while there are any
+	var dropped []string
+
+	for _, tok := range tokenize(s) {
+		switch tok.Type {
+		case html.TextToken:
+			if len(dropped) == 0 {
+				b.WriteString(escapeText(stripControl(tok.Data)))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedElements[tok.Data] {
+				if !voidElements[tok.Data] && tok.Type != html.SelfClosingTagToken {
+					dropped = append(dropped, tok.Data)
+				}
+				continue
+			}
+			attrs, ok := allowedElements[tok.Data]
+			if len(dropped) > 0 || !ok {
+				continue
+			}
+			closed := implicitlyClosed(open, tok.Data)
+			for _, name := range closed {
+				b.WriteString("</" + name + ">")
+			}
+			open = open[:len(open)-len(closed)]
+			b.WriteString("<" + tok.Data)
+			for _, name := range attrs {
+				value := strings.TrimSpace(stripControl(attr(tok, name)))
+				if value == "" || urlAttributes[name] && !SafeURL(value) {
+					continue
+				}
+				b.WriteString(" " + name + `="` + escapeAttribute(value) + `"`)
+			}
+			b.WriteString(">")
+			if !voidElements[tok.Data] {
+				open = append(open, tok.Data)
+			}
+		case html.EndTagToken:
+			if len(dropped) > 0 {
+				if dropped[len(dropped)-1] == tok.Data {
+					dropped = dropped[:len(dropped)-1]
+				}
+				continue
+			}
+			//close everything opened since the matching start tag, an end
+			//tag without one is ignored
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tok.Data {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+//elements a start tag ends without their end tag, innermost first, a list
+//item ends the previous item of its list and a block ends an open paragraph
+func implicitlyClosed(open []string, name string) []string {
+	var closed []string
+	for i := len(open) - 1; i >= 0; i-- {
+		switch {
+		case name == "li" && open[i] == "li",
+			blockElements[name] && open[i] == "p":
+			return append(closed, open[i])
+		case name == "li" && (open[i] == "ul" || open[i] == "ol"),
+			blockElements[open[i]]:
+			return nil
+		}
+		closed = append(closed, open[i])
+	}
+	return nil
+}
+
+//relative urls and http, https and mailto ones, browsers ignore whitespace
+//and control characters inside a scheme so they are ignored here as well
+func SafeURL(value string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+	colon := strings.IndexByte(cleaned, ':')
+	if colon < 0 || strings.ContainsAny(cleaned[:colon], "/?#") {
+		return true
+	}
+	switch strings.ToLower(cleaned[:colon]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+var (
+	textEscaper      = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attributeEscaper = strings.NewReplacer("&", "&amp;", `"`, "&quot;", "<", "&lt;", ">", "&gt;")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttribute(s string) string {
+	return attributeEscaper.Replace(s)
+}
+
+//make the urls in links, images and quotes of sanitized markup absolute
+//against base, fragment links within the page are left alone and urls that
+//resolve to anything but web and mail links are dropped
+func ResolveURLs(s string, base *url.URL) string {
+	var b strings.Builder
+	for _, tok := range tokenize(s) {
+		switch tok.Type {
+		case html.TextToken:
+			b.WriteString(escapeText(tok.Data))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			b.WriteString("<" + tok.Data)
+			for _, a := range tok.Attr {
+				value := a.Val
+				if urlAttributes[a.Key] && !strings.HasPrefix(value, "#") {
+					if ref, err := base.Parse(value); err == nil {
+						value = ref.String()
+					}
+					//a hostile base can turn a harmless relative url into a
+					//script url, so the result is checked again
+					if !SafeURL(value) {
+						continue
+					}
+				}
+				b.WriteString(" " + a.Key + `="` + escapeAttribute(value) + `"`)
+			}
+			b.WriteString(">")
+		case html.EndTagToken:
+			b.WriteString("</" + tok.Data + ">")
+		}
+	}
+	return b.String()
+}
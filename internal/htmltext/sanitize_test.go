@@ -0,0 +1,157 @@
+package htmltext
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "AT&T says 1 < 2", "AT&amp;T says 1 &lt; 2"},
+		{"entities kept escaped", "&lt;script&gt;alert(1)&lt;/script&gt;", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"allowed markup", `<p>Hi <b>bold</b> <em>em</em></p>`, `<p>Hi <b>bold</b> <em>em</em></p>`},
+		{"unknown elements unwrapped", `<section><font color="red">text</font></section>`, `text`},
+		{"attributes filtered", `<p class="x" style="color:red" id="y">t</p>`, `<p>t</p>`},
+		{"links kept", `<a href="https://example.com/?a=1&amp;b=2" title="T">x</a>`, `<a href="https://example.com/?a=1&amp;b=2" title="T">x</a>`},
+		{"relative and mail links kept", `<a href="/p/1">a</a><a href="mailto:me@example.com">b</a>`, `<a href="/p/1">a</a><a href="mailto:me@example.com">b</a>`},
+		{"image", `<img src="https://example.com/i.png" alt="cat" width="10">`, `<img src="https://example.com/i.png" alt="cat" width="10">`},
+		{"self closing", `a<br/>b<hr />c`, `a<br>b<hr>c`},
+		{"unclosed elements closed", `<p><b>bold <i>both`, `<p><b>bold <i>both</i></b></p>`},
+		{"misnested", `<b><i>x</b>y</i>`, `<b><i>x</i></b>y`},
+		{"stray end tags", `</p>text</div>`, `text`},
+		{"implicit list item end", `<ul><li>one<li>two</ul>`, `<ul><li>one</li><li>two</li></ul>`},
+		{"implicit paragraph end", `<p>one<p>two<div>three</div>`, `<p>one</p><p>two</p><div>three</div>`},
+		{"comments dropped", `a<!-- <script>alert(1)</script> -->b`, `ab`},
+		{"doctype dropped", `<!DOCTYPE html><?php echo 1 ?>x`, `x`},
+		{"attribute of control characters dropped", "<a href=\x7f title=\"\x1b\">x</a>", `<a>x</a>`},
+		{"lone less than", `a < b and a<3`, `a &lt; b and a&lt;3`},
+		{"upper case", `<P><B>x</B></P>`, `<p><b>x</b></p>`},
+		{"unquoted attributes", `<a href=https://example.com/ title=x>y</a>`, `<a href="https://example.com/" title="x">y</a>`},
+		{"quotes in attributes", `<img alt='say "hi"' src="a.png">`, `<img src="a.png" alt="say &quot;hi&quot;">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+//markup that must never survive, whatever else comes out
+var forbidden = []string{"<script", "javascript:", "vbscript:", "data:", "onerror", "onload", "onclick", "onmouseover", "<svg", "<math", "<iframe", "<style", "<object", "<embed", "<form", "\x1b", "\u009b"}
+
+func TestSanitizeXSS(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"script", `a<script>alert(1)</script>b`, `ab`},
+		{"script upper case", `a<SCRIPT>alert(1)</SCRIPT>b`, `ab`},
+		{"script markup inside", `a<script>document.write("<p>x</p>")</script>b`, `ab`},
+		{"script with attributes", `<script src="https://evil.example/x.js"></script>ok`, `ok`},
+		{"nested script", `<scr<script>ipt>alert(1)</script>`, `ipt&gt;alert(1)`},
+		{"script inside script", `<script><script>alert(1)</script>alert(2)</script>ok`, `alert(2)ok`},
+		{"double open", `<<script>alert(1)//<</script>`, `&lt;`},
+		{"unterminated script", `a<script>alert(1)`, `a`},
+		{"style", `<style>body{background:url(javascript:alert(1))}</style>ok`, `ok`},
+		{"event handler", `<p onclick="alert(1)">x</p>`, `<p>x</p>`},
+		{"event handler upper case", `<IMG SRC="a.png" ONERROR="alert(1)">`, `<img src="a.png">`},
+		{"event handler unquoted", `<img src=a.png onerror=alert(1)>`, `<img src="a.png">`},
+		{"event handler after slash", `<img/src="a.png"/onerror=alert(1)>`, `<img src="a.png">`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript mixed case", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript leading space", `<a href="  javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript tab inside", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"javascript newline inside", "<a href=\"java\nscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"javascript nul inside", "<a href=\"java\x00script:alert(1)\">x</a>", `<a>x</a>`},
+		{"javascript decimal entities", `<a href="&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript hex entities", `<a href="&#x6A;avascript&#x3A;alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript entity without semicolon", `<a href="&#106avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript named colon", `<a href="javascript&colon;alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript tab entity", `<a href="jav&#x09;ascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript newline entity", `<a href="jav&NewLine;ascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript img src", `<img src="javascript:alert(1)" alt="x">`, `<img alt="x">`},
+		{"vbscript", `<a href="vbscript:msgbox(1)">x</a>`, `<a>x</a>`},
+		{"data url", `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`, `<a>x</a>`},
+		{"data image", `<img src="data:image/svg+xml,<svg onload=alert(1)>">`, `<img>`},
+		{"blockquote cite", `<blockquote cite="javascript:alert(1)">q</blockquote>`, `<blockquote>q</blockquote>`},
+		{"svg", `a<svg onload="alert(1)"><script>alert(2)</script><a href="x">y</a></svg>b`, `ab`},
+		//the slash is not followed by > so the svg stays open
+		{"svg slash", `a<svg/onload=alert(1)>b`, `a`},
+		{"math", `a<math><mi xlink:href="javascript:alert(1)">x</mi></math>b`, `ab`},
+		{"iframe", `a<iframe src="javascript:alert(1)">fallback</iframe>b`, `ab`},
+		{"iframe srcdoc", `<iframe srcdoc="<script>alert(1)</script>"></iframe>ok`, `ok`},
+		{"object and embed", `a<object data="x.swf"><embed src="x.swf"></object>b`, `ab`},
+		{"form", `a<form action="https://evil.example"><input name="p"><button>go</button></form>b`, `ab`},
+		{"meta refresh", `<meta http-equiv="refresh" content="0;url=javascript:alert(1)">ok`, `ok`},
+		{"base", `<base href="javascript:alert(1)//">ok`, `ok`},
+		{"textarea breakout", `<textarea></textarea><script>alert(1)</script>`, ``},
+		{"title breakout", `<title></title><img src=x onerror=alert(1)>`, `<img src="x">`},
+		{"noscript", `<noscript><p title="</noscript><img src=x onerror=alert(1)>"></noscript>`, `<img src="x">"&gt;`},
+		{"unterminated tag", `a<img src="x" onerror="alert(1)"`, `a`},
+		{"unterminated attribute", `a<a href="javascript:alert(1)>b</a>`, `a`},
+		{"unterminated comment", `a<!-- <script>alert(1)</script>`, `a`},
+		{"escape in text", "red\x1b[31mtext\x1b]0;title\x07", "red[31mtext]0;title"},
+		{"csi in text", "a\u009b31mb", "a31mb"},
+		{"escape in attribute", "<a href=\"https://example.com/\x1b[2J\" title=\"\x1b[31m\">x</a>", `<a href="https://example.com/[2J" title="[31m">x</a>`},
+		{"escape entity", "a&#x1b;[31mb", "a[31mb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sanitize(tt.in)
+			if got != tt.want {
+				t.Errorf("Sanitize(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+			lower := strings.ToLower(got)
+			for _, bad := range forbidden {
+				if strings.Contains(lower, bad) {
+					t.Errorf("Sanitize(%q) = %q contains %q", tt.in, got, bad)
+				}
+			}
+			//sanitizing is stable, stored markup comes out the same again
+			if again := Sanitize(got); again != got {
+				t.Errorf("Sanitize is not idempotent on %q: %q", got, again)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	for _, value := range []string{"https://example.com/", "http://example.com/a:b", "mailto:me@example.com", "/path", "path/to:x", "?q=a:b", "#frag", "//example.com/x"} {
+		if !SafeURL(value) {
+			t.Errorf("SafeURL(%q) = false, want true", value)
+		}
+	}
+	for _, value := range []string{"javascript:alert(1)", " JAVASCRIPT:x", "java\tscript:x", "data:text/html,x", "vbscript:x", "file:///etc/passwd", "ftp://example.com/"} {
+		if SafeURL(value) {
+			t.Errorf("SafeURL(%q) = true, want false", value)
+		}
+	}
+}
+
+func TestResolveURLs(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post/")
+	in := `<p><a href="../other">a</a> <img src="img.png"> <a href="#top">t</a> <a href="https://elsewhere.example/">e</a> 1 &lt; 2</p>`
+	want := `<p><a href="https://example.com/blog/other">a</a> <img src="https://example.com/blog/post/img.png"> <a href="#top">t</a> <a href="https://elsewhere.example/">e</a> 1 &lt; 2</p>`
+	if got := ResolveURLs(in, base); got != want {
+		t.Errorf("ResolveURLs\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestResolveURLsHostileBase(t *testing.T) {
+	base, _ := url.Parse("javascript:alert(1)//")
+	got := ResolveURLs(`<a href="/y">y</a><img src="i.png" alt="i">`, base)
+	if strings.Contains(got, "javascript") {
+		t.Errorf("ResolveURLs against a script base = %q", got)
+	}
+	if want := `<a>y</a><img alt="i">`; got != want {
+		t.Errorf("ResolveURLs against a script base = %q, want %q", got, want)
+	}
+}
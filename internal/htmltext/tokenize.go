@@ -0,0 +1,47 @@
+package htmltext
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+//split a fragment of html into tokens with x/net/html, text and attribute
+//values come unescaped and names lower case, comments and doctypes are
+//dropped, the content of script and friends arrives as a single text token
+func tokenize(s string) []html.Token {
+	z := html.NewTokenizer(strings.NewReader(s))
+	var tokens []html.Token
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			//io.EOF, the tokenizer reads from memory and fails no other way
+			return tokens
+		case html.TextToken, html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			tokens = append(tokens, z.Token())
+		}
+	}
+}
+
+//the value of a tag's attribute, the first one when it is repeated
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+//control characters can drive the terminal, keep only line breaks and tabs
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r < 0x20 || r == 0x7f || r >= 0x80 && r < 0xa0 {
+			return -1
+		}
+		return r
+	}, s)
+}
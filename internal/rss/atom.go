@@ -0,0 +1,147 @@
+package rss
+
+import (
+	"encoding/xml"
+	"html"
+	"strings"
+)
+
+type atomFeed struct {
+	Base     string       `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title    atomText     `xml:"title"`
+	Subtitle atomText     `xml:"subtitle"`
+	Links    []atomLink   `xml:"link"`
+	Authors  []atomPerson `xml:"author"`
+	Entry    []atomEntry  `xml:"entry"`
+}
+
+type atomEntry struct {
+	Base       string         `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Summary    atomText       `xml:"summary"`
+	Content    atomText       `xml:"content"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	mediaElements
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Href   string `xml:"href,attr"`
+	Length string `xml:"length,attr"`
+}
+
+//atom text constructs are text, escaped html or inline xhtml
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return html.UnescapeString(strings.TrimSpace(t.Text))
+}
+
+//the construct as markup, plain text is escaped so it reads the same
+func (t atomText) html() string {
+	switch t.Type {
+	case "xhtml":
+		return strings.TrimSpace(t.Inner)
+	case "html", "text/html":
+		return strings.TrimSpace(t.Text)
+	case "", "text":
+		return html.EscapeString(strings.TrimSpace(t.Text))
+	}
+	//other media types are not meant for display
+	return ""
+}
+
+func parseAtom(body []byte) (*Feed, error) {
+	var af atomFeed
+	err := xml.Unmarshal(body, &af)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{
+		Title:       af.Title.String(),
+		Link:        alternateLink(af.Links),
+		Description: af.Subtitle.String(),
+		base:        strings.TrimSpace(af.Base),
+	}
+	for _, entry := range af.Entry {
+		item := Item{
+			ID:          strings.TrimSpace(entry.ID),
+			Title:       entry.Title.String(),
+			Link:        alternateLink(entry.Links),
+			Description: entry.Summary.html(),
+			Content:     entry.Content.html(),
+			Published:   strings.TrimSpace(entry.Published),
+			Updated:     strings.TrimSpace(entry.Updated),
+			Enclosures:  entry.mediaElements.enclosures(enclosureLinks(entry.Links)),
+			base:        strings.TrimSpace(entry.Base),
+		}
+		if item.Description == "" {
+			item.Description = item.Content
+		}
+		//entries without an author of their own are by the feed's authors
+		authors := entry.Authors
+		if len(authors) == 0 {
+			authors = af.Authors
+		}
+		var names []string
+		for _, author := range authors {
+			names = append(names, author.String())
+		}
+		item.Authors = uniqueNames(names)
+		var categories []string
+		for _, category := range entry.Categories {
+			categories = append(categories, category.String())
+		}
+		item.Categories = uniqueNames(categories)
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
+
+//atom attaches files as rel="enclosure" links
+func enclosureLinks(links []atomLink) []Enclosure {
+	var enclosures []Enclosure
+	for _, link := range links {
+		if link.Rel != "enclosure" {
+			continue
+		}
+		enclosures = append(enclosures, Enclosure{
+			URL:    link.Href,
+			Type:   link.Type,
+			Length: parseLength(link.Length),
+		})
+	}
+	return enclosures
+}
+
+//pick the rel="alternate" link, html first, a missing rel means alternate,
+//self, edit and enclosure links are never the page of an entry
+func alternateLink(links []atomLink) string {
+	found := ""
+	for _, link := range links {
+		if link.Rel != "" && link.Rel != "alternate" {
+			continue
+		}
+		if link.Type == "" || link.Type == "text/html" {
+			return link.Href
+		}
+		if found == "" {
+			found = link.Href
+		}
+	}
+	return found
+}
@@ -0,0 +1,62 @@
+package rss
+
+import (
+	"html"
+	"strings"
+)
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+func (p atomPerson) String() string {
+	if name := strings.TrimSpace(p.Name); name != "" {
+		return name
+	}
+	return strings.TrimSpace(p.Email)
+}
+
+//the label is meant for people, the term for machines
+func (c atomCategory) String() string {
+	if label := strings.TrimSpace(c.Label); label != "" {
+		return label
+	}
+	return strings.TrimSpace(c.Term)
+}
+
+//rss 2.0 <author> is an email address, usually written "jdoe@example.com (John Doe)",
+//some publishers use "John Doe <jdoe@example.com>" instead, the name wins when there is one
+func authorName(value string) string {
+	value = strings.TrimSpace(html.UnescapeString(value))
+	if open := strings.Index(value, "("); open > 0 && strings.HasSuffix(value, ")") {
+		if name := strings.TrimSpace(value[open+1 : len(value)-1]); name != "" {
+			return name
+		}
+	}
+	if open := strings.Index(value, "<"); open > 0 && strings.HasSuffix(value, ">") {
+		return strings.TrimSpace(value[:open])
+	}
+	return value
+}
+
+//trim, drop empty and repeated names, the first spelling of a name is kept
+func uniqueNames(names []string) []string {
+	var unique []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, name)
+	}
+	return unique
+}
@@ -0,0 +1,102 @@
+package rss
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+//the encoding attribute of an xml declaration at the very start of a document
+var xmlEncoding = regexp.MustCompile(`^(\s*<\?xml[^>]*?\sencoding\s*=\s*["'])([^"']*)(["'])`)
+
+//transcode an xml document to utf-8 and make its declaration say so, the
+//Content-Type charset wins over the declaration as long as it fits the bytes
+func toUTF8(body []byte, contentType string) ([]byte, error) {
+	//a byte order mark settles it
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return declareUTF8(body[3:]), nil
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}), bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return decode(body, "utf-16", unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM))
+	}
+
+	charset := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		charset = normalizeCharset(params["charset"])
+	}
+	//servers often claim utf-8 for whatever they serve
+	if charset == "" || (charset == "utf-8" && !utf8.Valid(body)) {
+		charset = ""
+		if m := xmlEncoding.FindSubmatch(body); m != nil {
+			charset = normalizeCharset(string(m[2]))
+		}
+	}
+	if charset == "" || charset == "utf-8" {
+		if utf8.Valid(body) {
+			return declareUTF8(body), nil
+		}
+		//undeclared legacy text is nearly always windows-1252
+		charset = "windows-1252"
+	}
+
+	//utf-16 without a byte order mark is big endian in xml, the web reads
+	//it as little endian
+	if charset == "utf-16" {
+		return decode(body, charset, unicode.UTF16(unicode.BigEndian, unicode.UseBOM))
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+	return decode(body, charset, enc)
+}
+
+func decode(body []byte, charset string, enc encoding.Encoding) ([]byte, error) {
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %v", charset, err)
+	}
+	return declareUTF8(decoded), nil
+}
+
+//fold the many labels of an encoding into its name the way browsers do,
+//which reads latin-1 as windows-1252 since feeds never mean C1 controls
+func normalizeCharset(label string) string {
+	label = strings.ToLower(strings.Trim(strings.TrimSpace(label), `"'`))
+	switch label {
+	case "":
+		return ""
+	//browsers read ascii as windows-1252 too, but servers claiming it
+	//mostly serve utf-8
+	case "us-ascii", "ascii":
+		return "utf-8"
+	case "utf-16", "utf16":
+		return "utf-16"
+	}
+	if enc, err := htmlindex.Get(label); err == nil {
+		if name, err := htmlindex.Name(enc); err == nil {
+			return name
+		}
+	}
+	return label
+}
+
+//encoding/xml refuses documents declaring anything but utf-8 unless given a
+//CharsetReader, so the declaration is brought in line with the bytes
+func declareUTF8(body []byte) []byte {
+	m := xmlEncoding.FindSubmatchIndex(body)
+	if m == nil || strings.EqualFold(string(body[m[4]:m[5]]), "utf-8") {
+		return body
+	}
+	out := make([]byte, 0, len(body))
+	out = append(out, body[:m[4]]...)
+	out = append(out, "UTF-8"...)
+	return append(out, body[m[5]:]...)
+}
@@ -0,0 +1,243 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+//an rss document with a prolog declaring encoding (none when empty) and a
+//title made of raw bytes
+func legacyFeed(encoding string, title []byte) []byte {
+	var b bytes.Buffer
+	if encoding != "" {
+		b.WriteString(`<?xml version="1.0" encoding="` + encoding + `"?>`)
+	}
+	b.WriteString(`<rss version="2.0"><channel><title>`)
+	b.Write(title)
+	b.WriteString(`</title></channel></rss>`)
+	return b.Bytes()
+}
+
+func utf16Bytes(s string, bigEndian bool) []byte {
+	var b []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			b = append(b, byte(unit>>8), byte(unit))
+		} else {
+			b = append(b, byte(unit), byte(unit>>8))
+		}
+	}
+	return b
+}
+
+//the channel title of a transcoded document, which must also be acceptable
+//to encoding/xml as is
+func transcodedTitle(t *testing.T, body []byte, contentType string) string {
+	t.Helper()
+	out, err := toUTF8(body, contentType)
+	if err != nil {
+		t.Fatalf("toUTF8: %v", err)
+	}
+	var feed RSSFeed
+	if err := xml.Unmarshal(out, &feed); err != nil {
+		t.Fatalf("xml.Unmarshal after toUTF8: %v\n%s", err, out)
+	}
+	return feed.Channel.Title
+}
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{
+			name: "utf-8",
+			body: legacyFeed("UTF-8", []byte("café “quoted”")),
+			want: "café “quoted”",
+		},
+		{
+			name: "utf-8 bom",
+			body: append([]byte{0xEF, 0xBB, 0xBF}, legacyFeed("UTF-8", []byte("café"))...),
+			want: "café",
+		},
+		{
+			name: "iso-8859-1",
+			body: legacyFeed("ISO-8859-1", []byte{'c', 'a', 'f', 0xE9, ' ', 0xA3, '5'}),
+			want: "café £5",
+		},
+		{
+			//latin-1 labels are read as windows-1252, like browsers do
+			name: "iso-8859-1 c1 range",
+			body: legacyFeed("ISO-8859-1", []byte{0x93, 'h', 'i', 0x94, ' ', 0x80, '5', ' ', 0x96, ' ', 0x85}),
+			want: "“hi” €5 – …",
+		},
+		{
+			name: "windows-1252 0x80-0x9f",
+			body: legacyFeed("windows-1252", []byte{0x80, 0x82, 0x84, 0x8A, 0x8C, 0x91, 0x92, 0x99, 0x9A, 0x9F}),
+			want: "€‚„ŠŒ‘’™šŸ",
+		},
+		{
+			name: "iso-8859-15",
+			body: legacyFeed("ISO-8859-15", []byte{0xA4, ' ', 0xA6, 0xA8, ' ', 0xBC, 0xBD, ' ', 0xE9}),
+			want: "€ Šš Œœ é",
+		},
+		{
+			name: "undeclared legacy bytes",
+			body: legacyFeed("", []byte{'c', 'a', 'f', 0xE9, ' ', 0x80}),
+			want: "café €",
+		},
+		{
+			name:        "content type charset",
+			body:        legacyFeed("", []byte{'c', 'a', 'f', 0xE9}),
+			contentType: "application/rss+xml; charset=iso-8859-1",
+			want:        "café",
+		},
+		{
+			//the header is what the server says it sent, it wins over the prolog
+			name:        "content type disagrees with prolog",
+			body:        legacyFeed("ISO-8859-1", []byte{0xA4, '5'}),
+			contentType: "text/xml; charset=ISO-8859-15",
+			want:        "€5",
+		},
+		{
+			//unless it claims utf-8 for bytes that are not
+			name:        "content type wrongly claims utf-8",
+			body:        legacyFeed("ISO-8859-15", []byte{0xA4, '5'}),
+			contentType: "application/xml; charset=utf-8",
+			want:        "€5",
+		},
+		{
+			name:        "content type utf-8 over legacy prolog",
+			body:        legacyFeed("ISO-8859-1", []byte("café")),
+			contentType: "application/xml; charset=utf-8",
+			want:        "café",
+		},
+		{
+			name: "utf-16 big endian bom",
+			body: append([]byte{0xFE, 0xFF}, utf16Bytes(string(legacyFeed("UTF-16", []byte("café €"))), true)...),
+			want: "café €",
+		},
+		{
+			name: "utf-16 little endian bom",
+			body: append([]byte{0xFF, 0xFE}, utf16Bytes(string(legacyFeed("UTF-16", []byte("café \U0001F600"))), false)...),
+			want: "café \U0001F600",
+		},
+		{
+			name:        "utf-16 big endian without bom",
+			body:        utf16Bytes(string(legacyFeed("UTF-16BE", []byte("café"))), true),
+			contentType: "application/rss+xml; charset=utf-16be",
+			want:        "café",
+		},
+		{
+			name:        "utf-16 little endian without bom",
+			body:        utf16Bytes(string(legacyFeed("UTF-16LE", []byte("café"))), false),
+			contentType: "application/rss+xml; charset=utf-16le",
+			want:        "café",
+		},
+		{
+			//xml takes utf-16 without a byte order mark to be big endian
+			name:        "utf-16 without bom",
+			body:        utf16Bytes(string(legacyFeed("UTF-16", []byte("café"))), true),
+			contentType: "application/rss+xml; charset=UTF-16",
+			want:        "café",
+		},
+		{
+			name:        "utf-16 bom over content type",
+			body:        append([]byte{0xFF, 0xFE}, utf16Bytes(string(legacyFeed("UTF-16", []byte("café"))), false)...),
+			contentType: "application/rss+xml; charset=utf-16be",
+			want:        "café",
+		},
+		{
+			name:        "us-ascii label on utf-8",
+			body:        legacyFeed("US-ASCII", []byte("café")),
+			contentType: "text/xml; charset=us-ascii",
+			want:        "café",
+		},
+		{
+			name: "iso-8859-2",
+			body: legacyFeed("ISO-8859-2", []byte{'Z', 'a', 0xBF, 0xF3, 0xB3, 0xE6}),
+			want: "Zażółć",
+		},
+		{
+			name: "koi8-r",
+			body: legacyFeed("KOI8-R", []byte{0xF0, 0xD2, 0xC9, 0xD7, 0xC5, 0xD4}),
+			want: "Привет",
+		},
+		{
+			name: "windows-1251",
+			body: legacyFeed("windows-1251", []byte{0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2}),
+			want: "Привет",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transcodedTitle(t, tt.body, tt.contentType); got != tt.want {
+				t.Errorf("title = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToUTF8UnknownCharset(t *testing.T) {
+	_, err := toUTF8(legacyFeed("x-no-such-charset", []byte("title")), "")
+	if err == nil || !strings.Contains(err.Error(), "unsupported charset") {
+		t.Errorf("toUTF8 with an unknown charset: got %v, want an unsupported charset error", err)
+	}
+}
+
+func TestDeclareUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "legacy declaration",
+			body: `<?xml version="1.0" encoding="ISO-8859-1"?><rss/>`,
+			want: `<?xml version="1.0" encoding="UTF-8"?><rss/>`,
+		},
+		{
+			name: "single quotes and spacing",
+			body: "  <?xml version='1.0'  encoding = 'windows-1252' standalone='yes'?><rss/>",
+			want: "  <?xml version='1.0'  encoding = 'UTF-8' standalone='yes'?><rss/>",
+		},
+		{
+			name: "already utf-8",
+			body: `<?xml version="1.0" encoding="utf-8"?><rss/>`,
+			want: `<?xml version="1.0" encoding="utf-8"?><rss/>`,
+		},
+		{
+			name: "no encoding",
+			body: `<?xml version="1.0"?><rss/>`,
+			want: `<?xml version="1.0"?><rss/>`,
+		},
+		{
+			name: "no declaration",
+			body: `<rss encoding="ISO-8859-1"/>`,
+			want: `<rss encoding="ISO-8859-1"/>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(declareUTF8([]byte(tt.body))); got != tt.want {
+				t.Errorf("declareUTF8(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedLegacyEncoding(t *testing.T) {
+	body := legacyFeed("ISO-8859-1", []byte{'c', 'a', 'f', 0xE9})
+	feed, err := ParseFeed(body, "")
+	if err != nil {
+		t.Fatalf("ParseFeed: %v", err)
+	}
+	if feed.Title != "café" {
+		t.Errorf("title = %q, want %q", feed.Title, "café")
+	}
+}
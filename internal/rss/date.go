@@ -0,0 +1,167 @@
+package rss
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//layouts tried in order once a date has been normalized, which strips the
+//weekday and rewrites known zone abbreviations as numeric offsets
+var dateLayouts = []string{
+	//rfc 822 / 1123 family used by rss 2.0
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -07:00",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04 MST",
+	"2 Jan 06 15:04:05 MST",
+	"2 Jan 06 15:04 MST",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+
+	//rfc 850 and the cookie dates derived from it
+	"2-Jan-06 15:04:05 -0700",
+	"2-Jan-2006 15:04:05 -0700",
+
+	//iso 8601 family used by atom, json feed and dublin core
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+
+	//unix and us style dates
+	"Mon Jan 2 15:04:05 -0700 2006",
+	"Mon Jan 2 15:04:05 2006",
+	"Jan 2, 2006 15:04:05 -0700",
+	"Jan 2, 2006 3:04 PM -0700",
+	"Jan 2, 2006 15:04:05",
+	"Jan 2, 2006 3:04 PM",
+	"Jan 2, 2006",
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+}
+
+//zone abbreviations time.Parse would otherwise record with a zero offset
+var zoneOffsets = map[string]string{
+	"Z":    "+0000",
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"MET":  "+0100",
+	"CEST": "+0200",
+	"MEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"IST":  "+0530",
+	"SGT":  "+0800",
+	"HKT":  "+0800",
+	"AWST": "+0800",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"ACST": "+0930",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+	"AST":  "-0400",
+	"ADT":  "-0300",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+}
+
+//parse a publish date as found in the wild, the result is in UTC
+func ParseDate(value string) (time.Time, error) {
+	normalized := normalizeDate(value)
+	if normalized == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date format: %q", value)
+}
+
+func normalizeDate(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+
+	//drop trailing comments such as "-0500 (EST)"
+	if strings.HasSuffix(value, ")") {
+		if i := strings.LastIndex(value, " ("); i > 0 {
+			value = value[:i]
+		}
+	}
+
+	//weekdays are redundant and often misspelled ("Tues", "Thur")
+	if i := strings.Index(value, ","); i > 0 && isLetters(value[:i]) {
+		value = strings.TrimSpace(value[i+1:])
+	}
+
+	fields := strings.Fields(value)
+	for i, field := range fields {
+		field = strings.TrimSuffix(field, ".")
+		if offset, ok := zoneOffsets[strings.ToUpper(field)]; ok && i > 0 {
+			fields[i] = offset
+			continue
+		}
+		//"Sept", "June" and "Nov." are common but the Jan layout only takes
+		//three letters
+		if len(field) >= 3 && isLetters(field) && i < 2 {
+			if month, ok := longMonth(field); ok {
+				fields[i] = month
+			}
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func isLetters(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+//shorten a spelled out or loosely abbreviated month name to its three letter form
+func longMonth(field string) (string, bool) {
+	lower := strings.ToLower(field)
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if strings.HasPrefix(name, lower) {
+			return m.String()[:3], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,108 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	want := time.Date(2015, time.November, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		//rfc 822 / 1123 family
+		{"rfc1123z", "Thu, 05 Nov 2015 10:00:00 +0000", want},
+		{"rfc1123 colon offset", "Thu, 05 Nov 2015 12:00:00 +02:00", want},
+		{"no seconds", "Thu, 05 Nov 2015 10:00 +0000", want},
+		{"two digit year", "Thu, 05 Nov 15 10:00:00 +0000", want},
+		{"two digit year no seconds", "05 Nov 15 10:00 +0000", want},
+		{"unknown zone", "Thu, 05 Nov 2015 10:00:00 XYZ", want},
+		{"unknown zone no seconds", "05 Nov 2015 10:00 XYZ", want},
+		{"two digit year unknown zone", "05 Nov 15 10:00:00 XYZ", want},
+		{"two digit year unknown zone no seconds", "05 Nov 15 10:00 XYZ", want},
+		{"no zone", "Thu, 05 Nov 2015 10:00:00", want},
+		{"no zone no seconds", "05 Nov 2015 10:00", want},
+		{"date only", "5 Nov 2015", time.Date(2015, time.November, 5, 0, 0, 0, 0, time.UTC)},
+		{"single digit day", "Thu, 5 Nov 2015 10:00:00 GMT", want},
+
+		//rfc 850
+		{"rfc850", "Thursday, 05-Nov-15 10:00:00 GMT", want},
+		{"cookie", "Thu, 05-Nov-2015 10:00:00 GMT", want},
+
+		//iso 8601 family
+		{"rfc3339", "2015-11-05T10:00:00Z", want},
+		{"rfc3339 offset", "2015-11-05T05:00:00-05:00", want},
+		{"rfc3339 fraction", "2015-11-05T10:00:00.123Z", want.Add(123 * time.Millisecond)},
+		{"basic offset", "2015-11-05T11:00:00+0100", want},
+		{"no seconds offset", "2015-11-05T10:00Z", want},
+		{"local", "2015-11-05T10:00:00", want},
+		{"local no seconds", "2015-11-05T10:00", want},
+		{"space separated zulu", "2015-11-05 10:00:00Z", want},
+		{"space separated offset", "2015-11-05 11:00:00 +0100", want},
+		{"space separated", "2015-11-05 10:00:00", want},
+		{"space separated no seconds", "2015-11-05 10:00", want},
+		{"iso date", "2015-11-05", time.Date(2015, time.November, 5, 0, 0, 0, 0, time.UTC)},
+		{"year month", "2015-11", time.Date(2015, time.November, 1, 0, 0, 0, 0, time.UTC)},
+		{"year", "2015", time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)},
+
+		//unix and us style
+		{"unix date", "Thu Nov 5 10:00:00 +0000 2015", want},
+		{"ansic", "Thu Nov 5 10:00:00 2015", want},
+		{"us with offset", "Nov 5, 2015 10:00:00 +0000", want},
+		{"us twelve hour offset", "Nov 5, 2015 10:00 AM +0000", want},
+		{"us", "Nov 5, 2015 10:00:00", want},
+		{"us twelve hour", "Nov 5, 2015 10:00 AM", want},
+		{"us date", "Nov 5, 2015", time.Date(2015, time.November, 5, 0, 0, 0, 0, time.UTC)},
+		{"slashes", "11/05/2015 10:00:00", want},
+		{"slashes date", "11/05/2015", time.Date(2015, time.November, 5, 0, 0, 0, 0, time.UTC)},
+
+		//normalizations
+		{"zone abbreviation", "Thu, 05 Nov 2015 05:00:00 EST", want},
+		{"summer zone abbreviation", "Thu, 05 Nov 2015 03:00:00 PDT", want},
+		{"half hour zone", "Thu, 05 Nov 2015 15:30:00 IST", want},
+		{"lower case zone", "Thu, 05 Nov 2015 11:00:00 cet", want},
+		{"ut", "Thu, 05 Nov 2015 10:00:00 UT", want},
+		{"zone comment", "Thu, 05 Nov 2015 05:00:00 -0500 (EST)", want},
+		{"misspelled weekday", "Thur, 05 Nov 2015 10:00:00 GMT", want},
+		{"long month", "05 November 2015 10:00:00 GMT", want},
+		{"sept", "Sat, 05 Sept 2015 10:00:00 GMT", time.Date(2015, time.September, 5, 10, 0, 0, 0, time.UTC)},
+		{"dotted month", "05 Nov. 2015 10:00:00 GMT", want},
+		{"long month us", "November 5, 2015", time.Date(2015, time.November, 5, 0, 0, 0, 0, time.UTC)},
+		{"extra whitespace", "  Thu,  05 Nov 2015\t10:00:00   GMT ", want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDate(tt.value)
+			if err != nil {
+				t.Fatalf("ParseDate(%q): %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("ParseDate(%q) is in %v, want UTC", tt.value, got.Location())
+			}
+		})
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	for _, value := range []string{"", "   ", "yesterday", "32 Foo 2015", "2015-13-45"} {
+		if got, err := ParseDate(value); err == nil {
+			t.Errorf("ParseDate(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+//every layout in dateLayouts is reachable through ParseDate
+func TestDateLayoutsRoundTrip(t *testing.T) {
+	at := time.Date(2015, time.November, 5, 10, 0, 0, 0, time.UTC)
+	for _, layout := range dateLayouts {
+		value := at.Format(layout)
+		if _, err := ParseDate(value); err != nil {
+			t.Errorf("layout %q: ParseDate(%q): %v", layout, value, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	linkTag   = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	baseTag   = regexp.MustCompile(`(?is)<base\b[^>]*>`)
+	attribute = regexp.MustCompile(`(?s)([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+)`)
+)
+
+//link types that announce a feed
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rdf+xml":   true,
+}
+
+//where sites without alternate links tend to keep their feed, tried in order
+var commonFeedPaths = []string{
+	"/feed",
+	"/rss",
+	"/feed.xml",
+	"/rss.xml",
+	"/atom.xml",
+	"/index.xml",
+	"/feed.json",
+	"/feeds/posts/default",
+}
+
+//a feed found for a url
+type FeedLink struct {
+	URL   string
+	Title string
+	Type  string
+	//the feed itself when discovery already fetched it, nil for links read
+	//off a page
+	Feed *Feed
+	Info ResponseInfo
+}
+
+//find the feeds a url leads to, the url itself when it is a feed, otherwise the
+//alternate links of the page it serves, and failing those a feed at a common path
+func (f *Fetcher) Discover(ctx context.Context, pageURL string) ([]FeedLink, error) {
+	body, contentType, info, err := f.get(ctx, pageURL, CacheHeaders{})
+	if err != nil {
+		return nil, err
+	}
+	feed, err := ParseFeed(body, contentType)
+	if err == nil {
+		return []FeedLink{fetchedLink(pageURL, feed, info)}, nil
+	}
+	if !errors.Is(err, ErrNotAFeed) {
+		return nil, err
+	}
+
+	base, err := url.Parse(info.FinalURL)
+	if err != nil {
+		return nil, err
+	}
+	links := feedLinks(string(body), base)
+	if len(links) > 0 {
+		return links, nil
+	}
+
+	root := &url.URL{Scheme: base.Scheme, Host: base.Host}
+	for _, path := range commonFeedPaths {
+		candidate := root.JoinPath(path).String()
+		feed, info, err := f.Fetch(ctx, candidate, CacheHeaders{})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			return []FeedLink{fetchedLink(candidate, feed, info)}, nil
+		}
+	}
+	return nil, ErrNotAFeed
+}
+
+//a feed discovery fetched, listed under the address it moved to for good
+func fetchedLink(feedURL string, feed *Feed, info ResponseInfo) FeedLink {
+	if info.MovedTo != "" {
+		feedURL = info.MovedTo
+	}
+	return FeedLink{URL: feedURL, Title: feed.Title, Feed: feed, Info: info}
+}
+
+//<link rel="alternate"> feeds of an html page, resolved against its <base> or its url
+func feedLinks(page string, pageURL *url.URL) []FeedLink {
+	if tag := baseTag.FindString(page); tag != "" {
+		if href := tagAttributes(tag)["href"]; href != "" {
+			if ref, err := pageURL.Parse(href); err == nil {
+				pageURL = ref
+			}
+		}
+	}
+
+	var links []FeedLink
+	seen := map[string]bool{}
+	for _, tag := range linkTag.FindAllString(page, -1) {
+		attrs := tagAttributes(tag)
+		if !hasToken(attrs["rel"], "alternate") {
+			continue
+		}
+		linkType := strings.ToLower(strings.TrimSpace(attrs["type"]))
+		if !feedTypes[linkType] || attrs["href"] == "" {
+			continue
+		}
+		ref, err := pageURL.Parse(strings.TrimSpace(attrs["href"]))
+		if err != nil || seen[ref.String()] {
+			continue
+		}
+		seen[ref.String()] = true
+		links = append(links, FeedLink{
+			URL:   ref.String(),
+			Title: attrs["title"],
+			Type:  linkType,
+		})
+	}
+	return links
+}
+
+func tagAttributes(tag string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range attribute.FindAllStringSubmatch(tag, -1) {
+		name := strings.ToLower(m[1])
+		if _, ok := attrs[name]; ok {
+			continue
+		}
+		attrs[name] = html.UnescapeString(strings.Trim(m[2], `"'`))
+	}
+	return attrs
+}
+
+//rel holds a space separated list of link types
+func hasToken(list, token string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
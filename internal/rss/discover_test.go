@@ -0,0 +1,106 @@
+package rss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	const feedBody = `<rss version="2.0"><channel><title>Site feed</title><item><title>a</title></item></channel></rss>`
+	var mu sync.Mutex
+	requests := map[string]int{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(feedBody))
+	})
+	mux.HandleFunc("/old.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/feed.xml", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" title="Posts" href="/feed.xml"></head></html>`))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html></html>`))
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		wantURL      string
+		wantFeed     bool
+		wantRequests map[string]int
+	}{
+		{
+			name:         "url is a feed",
+			path:         "/feed.xml",
+			wantURL:      server.URL + "/feed.xml",
+			wantFeed:     true,
+			wantRequests: map[string]int{"/feed.xml": 1},
+		},
+		{
+			name:         "feed moved for good",
+			path:         "/old.xml",
+			wantURL:      server.URL + "/feed.xml",
+			wantFeed:     true,
+			wantRequests: map[string]int{"/old.xml": 1, "/feed.xml": 1},
+		},
+		{
+			name:         "alternate link",
+			path:         "/page",
+			wantURL:      server.URL + "/feed.xml",
+			wantRequests: map[string]int{"/page": 1},
+		},
+		{
+			name:         "common path",
+			path:         "/nothing",
+			wantURL:      server.URL + "/feed.xml",
+			wantFeed:     true,
+			wantRequests: map[string]int{"/nothing": 1, "/feed": 1, "/rss": 1, "/feed.xml": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			clear(requests)
+			mu.Unlock()
+			fetcher := &Fetcher{Limiter: NewHostLimiter(2, 0)}
+			links, err := fetcher.Discover(context.Background(), server.URL+tt.path)
+			if err != nil {
+				t.Fatalf("Discover: %v", err)
+			}
+			if len(links) != 1 || links[0].URL != tt.wantURL {
+				t.Fatalf("links = %+v, want one to %s", links, tt.wantURL)
+			}
+			if got := links[0].Feed != nil; got != tt.wantFeed {
+				t.Errorf("feed fetched = %v, want %v", got, tt.wantFeed)
+			}
+			if tt.wantFeed && links[0].Feed.Title != "Site feed" {
+				t.Errorf("feed title = %q", links[0].Feed.Title)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(requests) != len(tt.wantRequests) {
+				t.Errorf("requests = %v, want %v", requests, tt.wantRequests)
+			}
+			for path, n := range tt.wantRequests {
+				if requests[path] != n {
+					t.Errorf("requests = %v, want %v", requests, tt.wantRequests)
+					break
+				}
+			}
+		})
+	}
+}
@@ -1,48 +1,299 @@
-package rss 
+package rss
 
 import (
 	"io"
-	"html"
+	"errors"
+	"fmt"
 	"context"
+	"crypto/tls"
 	"net/http"
-	"encoding/xml"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+const (
+	defaultTimeout     = 30 * time.Second
+	defaultMaxBodySize = 10 << 20
+	defaultUserAgent   = "gator"
+)
+
+//returned when the server answers a conditional request with 304
+var ErrNotModified = errors.New("feed not modified")
+
+var (
+	//the response is not a feed in any format we read, such as an html page
+	ErrNotAFeed = errors.New("not a feed")
+	//the response is larger than the fetcher's MaxBodySize
+	ErrTooLarge = errors.New("feed too large")
+	//the server answered with a status other than 200 or 304, see StatusError
+	ErrHTTPStatus = errors.New("unexpected http status")
+)
+
+//returned for unexpected status codes, matches ErrHTTPStatus
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrHTTPStatus, e.Status)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrHTTPStatus
+}
+
+//validators from a previous response, sent back on the next fetch
+type CacheHeaders struct {
+	ETag         string
+	LastModified string
+}
+
+//what the response said about itself, filled in on 304 as well
+type ResponseInfo struct {
+	Cache  CacheHeaders
+	MaxAge time.Duration
+	//where the feed now lives when it was reached through 301 or 308 redirects only
+	MovedTo string
+	//the url the response came from once all redirects were followed
+	FinalURL string
+}
+
+//fetches and parses feeds, the zero value is ready to use with the defaults
+type Fetcher struct {
+	//nil uses a client with the default timeout
+	Client *http.Client
+	//empty uses "gator"
+	UserAgent string
+	//sent with every request
+	Headers http.Header
+	//bodies larger than this are refused, 0 uses 10 MiB
+	MaxBodySize int64
+	//nil uses DefaultHostLimiter
+	Limiter *HostLimiter
+}
+
+//settings for NewFetcher, zero values take the defaults
+type FetcherOptions struct {
+	Timeout time.Duration
+	//proxy url, empty uses the environment, "direct" uses no proxy
+	Proxy       string
+	TLS         *tls.Config
+	UserAgent   string
+	Headers     http.Header
+	MaxBodySize int64
+	Limiter     *HostLimiter
+}
+
+var defaultClient = &http.Client{Timeout: defaultTimeout}
+
+//build a fetcher with its own client and transport
+func NewFetcher(opts FetcherOptions) (*Fetcher, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch opts.Proxy {
+	case "":
+	case "direct":
+		transport.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if opts.TLS != nil {
+		transport.TLSClientConfig = opts.TLS
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Fetcher{
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+		UserAgent:   opts.UserAgent,
+		Headers:     opts.Headers,
+		MaxBodySize: opts.MaxBodySize,
+		Limiter:     opts.Limiter,
+	}, nil
+}
+
+//fetch with a zero Fetcher
+func FetchFeed(ctx context.Context, feedURL string, cache CacheHeaders) (*Feed, ResponseInfo, error) {
+	return (&Fetcher{}).Fetch(ctx, feedURL, cache)
+}
+
+func (f *Fetcher) Fetch(ctx context.Context, feedURL string, cache CacheHeaders) (*Feed, ResponseInfo, error) {
+	body, contentType, info, err := f.get(ctx, feedURL, cache)
+	if err != nil {
+		return &Feed{}, info, err
+	}
+
+	//rss, atom and json feed are normalized into the same feed model
+	feed, err := ParseFeed(body, contentType)
+	if err != nil {
+		return &Feed{}, info, err
+	}
+
+	return feed, info, nil
+}
+
+//request a url the way feeds are requested and hand back the raw body and its content type
+func (f *Fetcher) get(ctx context.Context, feedURL string, cache CacheHeaders) ([]byte, string, ResponseInfo, error) {
+	info := ResponseInfo{Cache: cache}
+
 	//build request
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
-		return &RSSFeed{}, err
+		return nil, "", info, err
+	}
+	for name, values := range f.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	userAgent := f.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if cache.ETag != "" {
+		req.Header.Set("If-None-Match", cache.ETag)
+	}
+	if cache.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cache.LastModified)
+	}
+
+	//wait our turn with the host, the slot is held until the body is read
+	limiter := f.Limiter
+	if limiter == nil {
+		limiter = DefaultHostLimiter
+	}
+	host := req.URL.Hostname()
+	release, err := limiter.Wait(ctx, host)
+	if err != nil {
+		return nil, "", info, err
 	}
-	req.Header.Set("User-Agent", "gator")
+	defer release()
 
 	//perform request, get response
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	client := f.Client
+	if client == nil {
+		client = defaultClient
+	}
+	//watch redirects on a copy so the caller's client is left as it was
+	watched := *client
+	permanent := true
+	watched.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		//one temporary hop anywhere in the chain and the old url stays
+		code := next.Response.StatusCode
+		permanent = permanent && (code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect)
+		if permanent {
+			info.MovedTo = next.URL.String()
+		} else {
+			info.MovedTo = ""
+		}
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(next, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	resp, err := watched.Do(req)
 	if err != nil {
-		return &RSSFeed{}, err
+		return nil, "", info, err
 	}
 	defer resp.Body.Close()
+	info.FinalURL = resp.Request.URL.String()
 
-	//read and parse response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return &RSSFeed{}, err 
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		//the whole host is left alone, not just this feed
+		delay := retryAfter(resp.Header.Get("Retry-After"))
+		limiter.Backoff(host, delay)
+		return nil, "", info, &RateLimitedError{
+			Host:       host,
+			StatusCode: resp.StatusCode,
+			RetryAfter: delay,
+		}
+	}
+
+	info.MaxAge = maxAge(resp.Header.Get("Cache-Control"))
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", info, ErrNotModified
+	}
+	//error pages are never handed to the parsers
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", info, &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+	info.Cache = CacheHeaders{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
 	}
 
-	var feed RSSFeed
-	err = xml.Unmarshal(body, &feed)
+	//read response
+	contentType := resp.Header.Get("Content-Type")
+	if !feedContentType(contentType) {
+		return nil, "", info, fmt.Errorf("%w: served as %s", ErrNotAFeed, contentType)
+	}
+	maxBodySize := f.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+	//refuse up front when the server says how much is coming, and stop
+	//reading past the limit when it doesn't
+	if resp.ContentLength > maxBodySize {
+		return nil, "", info, fmt.Errorf("%w: %d bytes, limit is %d", ErrTooLarge, resp.ContentLength, maxBodySize)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
 	if err != nil {
-		return &RSSFeed{}, err
+		return nil, "", info, err
+	}
+	if int64(len(body)) > maxBodySize {
+		return nil, "", info, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, maxBodySize)
 	}
 
-	//construct output from response
-	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
-	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
-	for i, item := range feed.Channel.Item {
-		feed.Channel.Item[i].Title = html.UnescapeString(item.Title)
-		feed.Channel.Item[i].Description = html.UnescapeString(item.Description)
+	return body, contentType, info, nil
+}
+
+//feeds turn up under all sorts of text and xml types, only media that can't
+//hold one is turned away before reading the body
+func feedContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for _, prefix := range []string{"image/", "audio/", "video/", "font/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	switch mediaType {
+	case "application/octet-stream", "application/pdf", "application/zip", "application/gzip":
+		return false
 	}
+	return true
+}
 
-	return &feed, nil
-}
\ No newline at end of file
+//freshness lifetime from a Cache-Control header, zero when there is none
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchMovedTo(t *testing.T) {
+	const feedBody = `<rss version="2.0"><channel><title>t</title></channel></rss>`
+	mux := http.NewServeMux()
+	redirect := func(from, to string, code int) {
+		mux.HandleFunc(from, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, to, code)
+		})
+	}
+	redirect("/301", "/feed", http.StatusMovedPermanently)
+	redirect("/308-301", "/301", http.StatusPermanentRedirect)
+	redirect("/302", "/feed", http.StatusFound)
+	redirect("/301-302", "/302", http.StatusMovedPermanently)
+	redirect("/302-301", "/301", http.StatusFound)
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(feedBody))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		path    string
+		movedTo string
+	}{
+		{"/feed", ""},
+		{"/301", "/feed"},
+		{"/308-301", "/feed"},
+		{"/302", ""},
+		{"/301-302", ""},
+		{"/302-301", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			fetcher := &Fetcher{Limiter: NewHostLimiter(2, 0)}
+			_, info, err := fetcher.Fetch(context.Background(), server.URL+tt.path, CacheHeaders{})
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			want := ""
+			if tt.movedTo != "" {
+				want = server.URL + tt.movedTo
+			}
+			if info.MovedTo != want {
+				t.Errorf("MovedTo = %q, want %q", info.MovedTo, want)
+			}
+			if info.FinalURL != server.URL+"/feed" {
+				t.Errorf("FinalURL = %q", info.FinalURL)
+			}
+		})
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	const feedBody = `<rss version="2.0"><channel><title>t</title></channel></rss>`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Header().Set("Content-Length", strconv.Itoa(len(feedBody)+200))
+		w.Write([]byte(feedBody + strings.Repeat(" ", 200)))
+	})
+	mux.HandleFunc("/big-chunked", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		//flushing before the body leaves the length unknown
+		w.(http.Flusher).Flush()
+		w.Write([]byte(feedBody + strings.Repeat(" ", 200)))
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(feedBody))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!DOCTYPE html><html><head><title>t</title></head><body>hi</body></html>`))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want error
+		//tells apart the checks that can fail the same way
+		detail string
+	}{
+		{"/big", ErrTooLarge, "bytes, limit is"},
+		{"/big-chunked", ErrTooLarge, ": limit is"},
+		{"/error", ErrHTTPStatus, "500"},
+		{"/page", ErrNotAFeed, ""},
+		{"/image", ErrNotAFeed, "served as image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			fetcher := &Fetcher{Limiter: NewHostLimiter(2, 0), MaxBodySize: int64(len(feedBody) + 100)}
+			_, _, err := fetcher.Fetch(context.Background(), server.URL+tt.path, CacheHeaders{})
+			if !errors.Is(err, tt.want) || !strings.Contains(err.Error(), tt.detail) {
+				t.Fatalf("Fetch error = %v, want %v (%s)", err, tt.want, tt.detail)
+			}
+		})
+	}
+
+	t.Run("status error", func(t *testing.T) {
+		fetcher := &Fetcher{Limiter: NewHostLimiter(2, 0)}
+		_, _, err := fetcher.Fetch(context.Background(), server.URL+"/error", CacheHeaders{})
+		var status *StatusError
+		if !errors.As(err, &status) || status.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("Fetch error = %v, want a StatusError for 500", err)
+		}
+	})
+	t.Run("limit not reached", func(t *testing.T) {
+		fetcher := &Fetcher{Limiter: NewHostLimiter(2, 0), MaxBodySize: int64(len(feedBody) + 200)}
+		_, _, err := fetcher.Fetch(context.Background(), server.URL+"/big-chunked", CacheHeaders{})
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+	})
+}
+
+func TestFetchNotModified(t *testing.T) {
+	const (
+		feedBody     = `<rss version="2.0"><channel><title>t</title></channel></rss>`
+		etag         = `"v1"`
+		lastModified = "Thu, 05 Nov 2015 10:00:00 GMT"
+	)
+	var gotETag, gotSince string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotETag = r.Header.Get("If-None-Match")
+		gotSince = r.Header.Get("If-Modified-Since")
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Header().Set("Cache-Control", "max-age=600")
+		if gotETag == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(feedBody))
+	}))
+	defer server.Close()
+	fetcher := &Fetcher{Limiter: NewHostLimiter(2, 0)}
+
+	_, info, err := fetcher.Fetch(context.Background(), server.URL, CacheHeaders{})
+	if err != nil {
+		t.Fatalf("first Fetch: %v", err)
+	}
+	if gotETag != "" || gotSince != "" {
+		t.Errorf("first fetch sent If-None-Match %q and If-Modified-Since %q", gotETag, gotSince)
+	}
+	want := CacheHeaders{ETag: etag, LastModified: lastModified}
+	if info.Cache != want {
+		t.Fatalf("cache headers = %+v, want %+v", info.Cache, want)
+	}
+
+	_, info, err = fetcher.Fetch(context.Background(), server.URL, info.Cache)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("second Fetch error = %v, want ErrNotModified", err)
+	}
+	if gotETag != etag || gotSince != lastModified {
+		t.Errorf("second fetch sent If-None-Match %q and If-Modified-Since %q", gotETag, gotSince)
+	}
+	if info.Cache != want {
+		t.Errorf("cache headers after 304 = %+v, want %+v", info.Cache, want)
+	}
+	if info.MaxAge != 10*time.Minute {
+		t.Errorf("max age after 304 = %v", info.MaxAge)
+	}
+}
@@ -0,0 +1,128 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+//the version of a json feed is a url under this one, ".../version/1.1"
+const jsonFeedVersionPrefix = "https://jsonfeed.org/version/"
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	Description string     `json:"description"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            json.RawMessage  `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary"`
+	ContentHTML   string           `json:"content_html"`
+	ContentText   string           `json:"content_text"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonAuthor     `json:"authors"`
+	Author        *jsonAuthor      `json:"author"` //json feed 1.0
+	Image         string           `json:"image"`
+	Tags          []string         `json:"tags"`
+	Attachments   []jsonAttachment `json:"attachments"`
+}
+
+type jsonAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+//json feed is served as application/feed+json, older servers use application/json
+func isJSONFeed(body []byte, contentType string) bool {
+	if strings.Contains(contentType, "feed+json") {
+		return true
+	}
+	//an xml document can never start with a brace
+	trimmed := strings.TrimLeft(string(body), " \t\r\n\ufeff")
+	return strings.HasPrefix(trimmed, "{")
+}
+
+func parseJSONFeed(body []byte) (*Feed, error) {
+	//encoding/json refuses a byte order mark
+	body = bytes.TrimPrefix(body, []byte("\ufeff"))
+	var jf jsonFeed
+	err := json.Unmarshal(body, &jf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotAFeed, err)
+	}
+	//any json api answers with an object, a feed says which version it follows
+	if !strings.HasPrefix(jf.Version, jsonFeedVersionPrefix) {
+		return nil, fmt.Errorf("%w: json without a json feed version", ErrNotAFeed)
+	}
+
+	feed := &Feed{
+		Title:       jf.Title,
+		Link:        jf.HomePageURL,
+		Description: jf.Description,
+	}
+	for _, ji := range jf.Items {
+		item := Item{
+			ID:          jsonID(ji.ID),
+			Title:       ji.Title,
+			Link:        ji.URL,
+			Description: html.EscapeString(ji.Summary),
+			Content:     ji.ContentHTML,
+			Published:   ji.DatePublished,
+			Updated:     ji.DateModified,
+		}
+		if item.Content == "" {
+			item.Content = html.EscapeString(ji.ContentText)
+		}
+		if item.Description == "" {
+			item.Description = item.Content
+		}
+		for _, author := range ji.Authors {
+			item.Authors = append(item.Authors, author.Name)
+		}
+		if len(item.Authors) == 0 && ji.Author != nil {
+			item.Authors = append(item.Authors, ji.Author.Name)
+		}
+		item.Authors = uniqueNames(item.Authors)
+		item.Categories = uniqueNames(ji.Tags)
+		var enclosures []Enclosure
+		for _, attachment := range ji.Attachments {
+			enclosures = append(enclosures, Enclosure{
+				URL:      attachment.URL,
+				Type:     attachment.MimeType,
+				Length:   max(attachment.SizeInBytes, 0),
+				Duration: time.Duration(max(attachment.DurationInSeconds, 0)) * time.Second,
+			})
+		}
+		if ji.Image != "" {
+			enclosures = append(enclosures, Enclosure{URL: ji.Image, Thumbnail: true})
+		}
+		item.Enclosures = mergeEnclosures(enclosures)
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
+
+//the spec says id is a string, but plenty of publishers emit numbers
+func jsonID(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
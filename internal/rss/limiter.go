@@ -0,0 +1,160 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//requests in flight to one host at a time
+	defaultHostConcurrency = 2
+	//gap between the starts of two requests to one host
+	defaultHostInterval = time.Second
+	//how long a host is left alone after a 429 or 503 without Retry-After
+	defaultRetryAfter = time.Minute
+	//Retry-After values beyond this are not taken at their word
+	maxRetryAfter = 24 * time.Hour
+)
+
+//returned when a host answers 429 or 503, or is still backed off from an
+//earlier answer, RetryAfter is how long it asked to be left alone
+type RateLimitedError struct {
+	Host       string
+	StatusCode int
+	RetryAfter time.Duration
+	//the request ran out of time waiting its turn with the host, too many
+	//feeds share it to get through in one go
+	Queued bool
+}
+
+func (e *RateLimitedError) Error() string {
+	if e.Queued {
+		return fmt.Sprintf("gave up waiting for a turn with host %s", e.Host)
+	}
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("host %s is backed off for another %s", e.Host, e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("host %s answered %d, retry after %s", e.Host, e.StatusCode, e.RetryAfter.Round(time.Second))
+}
+
+//a 429 or 503 is an unexpected status too
+func (e *RateLimitedError) Is(target error) bool {
+	return target == ErrHTTPStatus && e.StatusCode != 0
+}
+
+//caps concurrent and per-second requests to each hostname, shared by every
+//fetch in the process
+type HostLimiter struct {
+	concurrency int
+	interval    time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	slots chan struct{}
+	//earliest start of the next request
+	next time.Time
+	//set from Retry-After, no requests until then
+	blockedUntil time.Time
+}
+
+func NewHostLimiter(concurrency int, interval time.Duration) *HostLimiter {
+	return &HostLimiter{
+		concurrency: max(concurrency, 1),
+		interval:    interval,
+		hosts:       make(map[string]*hostState),
+	}
+}
+
+//used by fetchers that don't bring their own
+var DefaultHostLimiter = NewHostLimiter(defaultHostConcurrency, defaultHostInterval)
+
+func (l *HostLimiter) host(name string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hosts[name]
+	if !ok {
+		h = &hostState{slots: make(chan struct{}, l.concurrency)}
+		l.hosts[name] = h
+	}
+	return h
+}
+
+//block until a request to host may start, the returned func frees its slot
+//once the request is done, a host still backed off fails right away, and a
+//deadline passing in the queue is reported as rate limiting since the time
+//went to other requests and not to this one
+func (l *HostLimiter) Wait(ctx context.Context, host string) (func(), error) {
+	h := l.host(host)
+
+	l.mu.Lock()
+	blocked := time.Until(h.blockedUntil)
+	l.mu.Unlock()
+	if blocked > 0 {
+		return nil, &RateLimitedError{Host: host, RetryAfter: blocked}
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, queueError(ctx, host, 0)
+	}
+	release := func() { <-h.slots }
+
+	//reserve a start time, requests queue up one interval apart
+	l.mu.Lock()
+	start := time.Now()
+	if h.next.After(start) {
+		start = h.next
+	}
+	h.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return release, nil
+	case <-ctx.Done():
+		release()
+		return nil, queueError(ctx, host, time.Until(start))
+	}
+}
+
+//why a wait was cut short, cancellation is passed on as it is
+func queueError(ctx context.Context, host string, remaining time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &RateLimitedError{Host: host, RetryAfter: max(remaining, 0), Queued: true}
+	}
+	return ctx.Err()
+}
+
+//keep requests off host for the given time
+func (l *HostLimiter) Backoff(host string, delay time.Duration) {
+	h := l.host(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(delay); until.After(h.blockedUntil) {
+		h.blockedUntil = until
+	}
+}
+
+//Retry-After holds either a number of seconds or an http date
+func retryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	delay := defaultRetryAfter
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		delay = time.Until(t)
+	}
+	return min(max(delay, 0), maxRetryAfter)
+}
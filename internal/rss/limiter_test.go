@@ -0,0 +1,52 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterWaitDeadline(t *testing.T) {
+	limiter := NewHostLimiter(1, time.Hour)
+	release, err := limiter.Wait(context.Background(), "a.example")
+	if err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+
+	//the only slot is taken
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Wait(ctx, "a.example")
+	var limited *RateLimitedError
+	if !errors.As(err, &limited) || !limited.Queued {
+		t.Fatalf("Wait for a full host = %v, want a queued RateLimitedError", err)
+	}
+	if errors.Is(err, ErrHTTPStatus) {
+		t.Errorf("a queued request counts as an unexpected status")
+	}
+	release()
+
+	//the slot is free but the next start is an interval away
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Wait(ctx, "a.example")
+	if !errors.As(err, &limited) || !limited.Queued || limited.RetryAfter <= 0 {
+		t.Fatalf("Wait for a spaced out host = %v, want a queued RateLimitedError with a delay", err)
+	}
+
+	//shutting down is not rate limiting
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = limiter.Wait(ctx, "a.example")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait after cancel = %v, want context.Canceled", err)
+	}
+
+	//another host is unaffected
+	release, err = limiter.Wait(context.Background(), "b.example")
+	if err != nil {
+		t.Fatalf("Wait for another host: %v", err)
+	}
+	release()
+}
@@ -0,0 +1,72 @@
+package rss
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/samassembly/gator/internal/htmltext"
+)
+
+//make the links of a feed absolute, item links, enclosures and the urls
+//inside descriptions and content are resolved against the xml:base in scope,
+//failing that against the channel link, and failing that against the url the
+//feed was fetched from
+func (feed *Feed) ResolveLinks(feedURL string) {
+	base, err := url.Parse(strings.TrimSpace(feedURL))
+	if err != nil {
+		return
+	}
+	feed.Link = resolveURL(withBase(base, feed.base), feed.Link)
+	if feed.base != "" {
+		base = withBase(base, feed.base)
+	} else if site, err := url.Parse(feed.Link); err == nil && isWebURL(site) {
+		base = site
+	}
+
+	for i := range feed.Items {
+		item := &feed.Items[i]
+		itemBase := withBase(base, item.base)
+		item.Link = resolveURL(itemBase, item.Link)
+		for j := range item.Enclosures {
+			item.Enclosures[j].URL = resolveURL(itemBase, item.Enclosures[j].URL)
+		}
+		item.Description = htmltext.ResolveURLs(item.Description, itemBase)
+		item.Content = htmltext.ResolveURLs(item.Content, itemBase)
+	}
+}
+
+//the base url in scope under an element carrying xml:base, a relative
+//xml:base is relative to the one outside it, and one that is not a web
+//address is ignored since the feed could otherwise turn every relative link
+//into a script
+func withBase(base *url.URL, xmlBase string) *url.URL {
+	if xmlBase == "" {
+		return base
+	}
+	ref, err := base.Parse(strings.TrimSpace(xmlBase))
+	if err != nil || !isWebURL(ref) {
+		return base
+	}
+	return ref
+}
+
+//unparseable links are left as they are, and links that end up as anything
+//but web and mail addresses are dropped
+func resolveURL(base *url.URL, link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return link
+	}
+	if ref, err := base.Parse(link); err == nil {
+		link = ref.String()
+	}
+	if !htmltext.SafeURL(link) {
+		return ""
+	}
+	return link
+}
+
+func isWebURL(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	return (scheme == "http" || scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,99 @@
+package rss
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveLinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		feedURL     string
+		wantSite    string
+		wantLink    string
+		wantContent string
+		wantEnclose string
+	}{
+		{
+			name:        "channel link",
+			body:        `<rss version="2.0"><channel><title>t</title><link>https://site.example/blog/</link><item><title>a</title><link>/posts/42</link><description>&lt;a href="p/1"&gt;x&lt;/a&gt; &lt;img src="img.png"&gt; &lt;a href="#top"&gt;t&lt;/a&gt;</description><enclosure url="ep.mp3" type="audio/mpeg"/></item></channel></rss>`,
+			feedURL:     "https://feeds.example/rss.xml",
+			wantSite:    "https://site.example/blog/",
+			wantLink:    "https://site.example/posts/42",
+			wantContent: `<a href="https://site.example/blog/p/1">x</a> <img src="https://site.example/blog/img.png"> <a href="#top">t</a>`,
+			wantEnclose: "https://site.example/blog/ep.mp3",
+		},
+		{
+			name:     "feed url when the channel link is relative",
+			body:     `<rss version="2.0"><channel><title>t</title><link>/</link><item><title>a</title><link>posts/42</link></item></channel></rss>`,
+			feedURL:  "https://site.example/feeds/rss.xml",
+			wantSite: "https://site.example/",
+			wantLink: "https://site.example/posts/42",
+		},
+		{
+			name:     "item xml:base",
+			body:     `<rss version="2.0"><channel><title>t</title><link>https://site.example/</link><item xml:base="https://cdn.example/x/"><title>a</title><link>y</link></item></channel></rss>`,
+			feedURL:  "https://site.example/rss.xml",
+			wantSite: "https://site.example/",
+			wantLink: "https://cdn.example/x/y",
+		},
+		{
+			name:     "atom xml:base",
+			body:     `<feed xmlns="http://www.w3.org/2005/Atom" xml:base="https://a.example/base/"><title>x</title><link href="/"/><entry><title>a</title><link href="e/1"/></entry></feed>`,
+			feedURL:  "https://feeds.example/atom.xml",
+			wantSite: "https://a.example/",
+			wantLink: "https://a.example/base/e/1",
+		},
+		{
+			name:     "json feed",
+			body:     `{"version":"https://jsonfeed.org/version/1.1","title":"j","items":[{"id":"1","url":"/j/1","content_html":"<img src=a.png>"}]}`,
+			feedURL:  "https://j.example/feed.json",
+			wantLink: "https://j.example/j/1",
+			//json feed descriptions fall back to the content
+			wantContent: `<img src="https://j.example/a.png">`,
+		},
+		{
+			//a script base is ignored rather than applied to every link
+			name:        "script xml:base",
+			body:        `<feed xmlns="http://www.w3.org/2005/Atom" xml:base="javascript:alert(1)//"><title>x</title><entry xml:base="javascript:alert(2)//"><title>a</title><link href="/p"/><content type="html">&lt;a href="/y"&gt;y&lt;/a&gt;</content></entry></feed>`,
+			feedURL:     "https://feeds.example/atom.xml",
+			wantLink:    "https://feeds.example/p",
+			wantContent: `<a href="https://feeds.example/y">y</a>`,
+		},
+		{
+			name:     "script links dropped",
+			body:     `<rss version="2.0"><channel><title>t</title><link>javascript:alert(1)</link><item><title>a</title><link>javascript:alert(2)</link></item></channel></rss>`,
+			feedURL:  "https://site.example/rss.xml",
+			wantSite: "",
+			wantLink: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := ParseFeed([]byte(tt.body), "")
+			if err != nil {
+				t.Fatalf("ParseFeed: %v", err)
+			}
+			feed.ResolveLinks(tt.feedURL)
+			if feed.Link != tt.wantSite {
+				t.Errorf("feed link = %q, want %q", feed.Link, tt.wantSite)
+			}
+			item := feed.Items[0]
+			if item.Link != tt.wantLink {
+				t.Errorf("item link = %q, want %q", item.Link, tt.wantLink)
+			}
+			if tt.wantContent != "" && item.Description != tt.wantContent {
+				t.Errorf("description = %q, want %q", item.Description, tt.wantContent)
+			}
+			if tt.wantEnclose != "" && (len(item.Enclosures) == 0 || item.Enclosures[0].URL != tt.wantEnclose) {
+				t.Errorf("enclosures = %v, want %q", item.Enclosures, tt.wantEnclose)
+			}
+			for _, text := range []string{feed.Link, item.Link, item.Description, item.Content} {
+				if strings.Contains(strings.ToLower(text), "javascript:") {
+					t.Errorf("script url survived: %q", text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package rss
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+//namespaces of the media rss and itunes podcast extensions
+const (
+	mediaNS  = "http://search.yahoo.com/mrss/"
+	itunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+)
+
+//a file attached to an item, such as a podcast episode, Length and Duration
+//are zero when the feed doesn't say
+type Enclosure struct {
+	URL      string
+	Type     string
+	Length   int64
+	Duration time.Duration
+	//an image illustrating the item rather than its payload, from
+	//media:thumbnail, itunes:image or a json feed image
+	Thumbnail bool
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type mediaContent struct {
+	URL        string           `xml:"url,attr"`
+	Type       string           `xml:"type,attr"`
+	FileSize   string           `xml:"fileSize,attr"`
+	Duration   string           `xml:"duration,attr"`
+	Thumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type mediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+type mediaGroup struct {
+	Contents   []mediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+//media rss and itunes elements, embedded in rss items and atom entries
+type mediaElements struct {
+	MediaContents   []mediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaGroups     []mediaGroup     `xml:"http://search.yahoo.com/mrss/ group"`
+
+	ITunesDuration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	ITunesImage    struct {
+		Href string `xml:"href,attr"`
+	} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+}
+
+//gather the enclosures of an item from everywhere feeds put them, the same
+//url announced in several places ends up as one enclosure
+func (m mediaElements) enclosures(found []Enclosure) []Enclosure {
+	contents := m.MediaContents
+	thumbnails := m.MediaThumbnails
+	for _, group := range m.MediaGroups {
+		contents = append(contents, group.Contents...)
+		thumbnails = append(thumbnails, group.Thumbnails...)
+	}
+
+	for _, content := range contents {
+		found = append(found, Enclosure{
+			URL:      content.URL,
+			Type:     content.Type,
+			Length:   parseLength(content.FileSize),
+			Duration: parseDuration(content.Duration),
+		})
+		thumbnails = append(thumbnails, content.Thumbnails...)
+	}
+	for _, thumbnail := range thumbnails {
+		found = append(found, Enclosure{URL: thumbnail.URL, Thumbnail: true})
+	}
+	if m.ITunesImage.Href != "" {
+		found = append(found, Enclosure{URL: m.ITunesImage.Href, Thumbnail: true})
+	}
+
+	merged := mergeEnclosures(found)
+	//itunes:duration is the length of the episode, so of its first media file
+	if duration := parseDuration(m.ITunesDuration); duration > 0 {
+		for i := range merged {
+			if merged[i].Thumbnail {
+				continue
+			}
+			if merged[i].Duration == 0 {
+				merged[i].Duration = duration
+			}
+			break
+		}
+	}
+	return merged
+}
+
+//drop empty urls and fold repeated ones together, keeping the first position
+func mergeEnclosures(found []Enclosure) []Enclosure {
+	var merged []Enclosure
+	index := map[string]int{}
+	for _, enclosure := range found {
+		enclosure.URL = strings.TrimSpace(enclosure.URL)
+		enclosure.Type = strings.TrimSpace(enclosure.Type)
+		if enclosure.URL == "" {
+			continue
+		}
+		i, ok := index[enclosure.URL]
+		if !ok {
+			index[enclosure.URL] = len(merged)
+			merged = append(merged, enclosure)
+			continue
+		}
+		existing := &merged[i]
+		if existing.Type == "" {
+			existing.Type = enclosure.Type
+		}
+		if existing.Length == 0 {
+			existing.Length = enclosure.Length
+		}
+		if existing.Duration == 0 {
+			existing.Duration = enclosure.Duration
+		}
+		existing.Thumbnail = existing.Thumbnail && enclosure.Thumbnail
+	}
+	return merged
+}
+
+//a byte count, publishers put 0 or junk here when they don't know
+func parseLength(value string) int64 {
+	length, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || length < 0 {
+		return 0
+	}
+	return length
+}
+
+//seconds, or [[hh:]mm:]ss as itunes:duration allows, fractions are dropped
+func parseDuration(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	var seconds float64
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -1,17 +1,128 @@
 package rss
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+//format-agnostic feed, every decoder normalizes into this
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	Items       []Item
+
+	//publisher scheduling hints, zero when the feed gives none
+	UpdateInterval time.Duration
+	SkipHours      []int
+	SkipDays       []time.Weekday
+
+	//xml:base of the channel, see ResolveLinks
+	base string
+}
+
+type Item struct {
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	Content     string
+	Published   string
+	Updated     string
+	Authors     []string
+	Categories  []string
+	Enclosures  []Enclosure
+
+	//xml:base of the item
+	base string
+}
+
+//stable identity of an item within its feed, the guid/id when the
+//publisher provides one and otherwise a hash of link and title
+func (item Item) GUID() string {
+	if item.ID != "" {
+		return item.ID
+	}
+	sum := sha256.Sum256([]byte(item.Link + "\n" + item.Title))
+	return hex.EncodeToString(sum[:])
+}
+
+//fingerprint of the parts of an item a publisher may edit after the fact,
+//enclosures, authors and categories only count when there are any so older
+//hashes stay valid
+func (item Item) ContentHash() string {
+	text := item.Title + "\n" + item.Link + "\n" + item.Description + "\n" + item.Content + "\n" + item.Updated
+	for _, enclosure := range item.Enclosures {
+		text += "\n" + enclosure.URL
+	}
+	for _, author := range item.Authors {
+		text += "\nauthor:" + author
+	}
+	for _, category := range item.Categories {
+		text += "\ncategory:" + category
+	}
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
 type RSSFeed struct {
 	Channel struct {
+		Base        string    `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
+		Links       rssLinks  `xml:"link"`
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
+		TTL         string    `xml:"ttl"`
+		SkipHours   skipHours `xml:"skipHours"`
+		SkipDays    skipDays  `xml:"skipDays"`
+
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
 	} `xml:"channel"`
 }
 
+//a bare link tag also matches namespaced ones such as <atom:link rel="self">,
+//so every one is kept and the plain rss <link> picked out afterwards
+type rssLink struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+type rssLinks []rssLink
+
+//namespaces rss itself puts its elements in, rss 2.0 uses none
+var rssNamespaces = map[string]bool{
+	"":                                       true,
+	"http://purl.org/rss/1.0/":               true,
+	"http://my.netscape.com/rdf/simple/0.9/": true,
+}
+
+//the text of the first link in rss's own namespace
+func (links rssLinks) String() string {
+	for _, link := range links {
+		if rssNamespaces[link.XMLName.Space] {
+			return strings.TrimSpace(link.Text)
+		}
+	}
+	return ""
+}
+
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-}
\ No newline at end of file
+	Base        string         `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	GUID        string         `xml:"guid"`
+	Title       string         `xml:"title"`
+	Links       rssLinks       `xml:"link"`
+	Description string         `xml:"description"`
+	PubDate     string         `xml:"pubDate"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+	//<author> and itunes:author
+	Authors    []string `xml:"author"`
+	Creators   []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories []string `xml:"category"`
+	//full article, description is often just a teaser
+	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	mediaElements
+}
@@ -0,0 +1,118 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/samassembly/gator/internal/htmltext"
+)
+
+//detect the feed format from the content type or document root and decode it,
+//item descriptions and content come out as sanitized html
+func ParseFeed(body []byte, contentType string) (*Feed, error) {
+	feed, err := decodeFeed(body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	for i := range feed.Items {
+		feed.Items[i].Description = htmltext.Sanitize(feed.Items[i].Description)
+		feed.Items[i].Content = htmltext.Sanitize(feed.Items[i].Content)
+	}
+	return feed, nil
+}
+
+func decodeFeed(body []byte, contentType string) (*Feed, error) {
+	if isJSONFeed(body, contentType) {
+		return parseJSONFeed(body)
+	}
+
+	//legacy encodings are transcoded before any xml decoding
+	body, err := toUTF8(body, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := rootElement(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotAFeed, err)
+	}
+
+	switch root.Local {
+	case "rss":
+		return parseRSS(body)
+	case "feed":
+		return parseAtom(body)
+	case "RDF":
+		return parseRDF(body)
+	case "html":
+		return nil, fmt.Errorf("%w: got an html page", ErrNotAFeed)
+	}
+	return nil, fmt.Errorf("%w: unrecognized root element <%s>", ErrNotAFeed, root.Local)
+}
+
+func rootElement(body []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, errors.New("no root element found")
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+func parseRSS(body []byte) (*Feed, error) {
+	var rf RSSFeed
+	err := xml.Unmarshal(body, &rf)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{
+		Title:          html.UnescapeString(rf.Channel.Title),
+		Link:           rf.Channel.Links.String(),
+		Description:    html.UnescapeString(rf.Channel.Description),
+		UpdateInterval: updateInterval(rf.Channel.TTL, rf.Channel.UpdatePeriod, rf.Channel.UpdateFrequency),
+		SkipHours:      rf.Channel.SkipHours.hours(),
+		SkipDays:       rf.Channel.SkipDays.days(),
+		base:           strings.TrimSpace(rf.Channel.Base),
+	}
+	for _, ri := range rf.Channel.Item {
+		var enclosures []Enclosure
+		for _, enclosure := range ri.Enclosures {
+			enclosures = append(enclosures, Enclosure{
+				URL:    enclosure.URL,
+				Type:   enclosure.Type,
+				Length: parseLength(enclosure.Length),
+			})
+		}
+		var authors []string
+		for _, author := range ri.Authors {
+			authors = append(authors, authorName(author))
+		}
+		authors = append(authors, ri.Creators...)
+		var categories []string
+		for _, category := range ri.Categories {
+			categories = append(categories, html.UnescapeString(category))
+		}
+		feed.Items = append(feed.Items, Item{
+			ID:          strings.TrimSpace(ri.GUID),
+			Title:       html.UnescapeString(ri.Title),
+			Link:        ri.Links.String(),
+			Description: strings.TrimSpace(ri.Description),
+			Content:     strings.TrimSpace(ri.ContentEncoded),
+			Published:   strings.TrimSpace(ri.PubDate),
+			Authors:     uniqueNames(authors),
+			Categories:  uniqueNames(categories),
+			Enclosures:  ri.mediaElements.enclosures(enclosures),
+			base:        strings.TrimSpace(ri.Base),
+		})
+	}
+	return feed, nil
+}
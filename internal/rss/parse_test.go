@@ -0,0 +1,212 @@
+package rss
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFeedLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantSite string
+		wantLink string
+	}{
+		{
+			//hugo's default template puts the self link after the site link
+			name: "atom self link after the channel link",
+			body: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>t</title>
+<link>https://site.example/</link>
+<atom:link href="https://site.example/index.xml" rel="self" type="application/rss+xml"/>
+<item><title>a</title><link>https://site.example/a/</link></item></channel></rss>`,
+			wantSite: "https://site.example/",
+			wantLink: "https://site.example/a/",
+		},
+		{
+			name: "atom self link before the channel link",
+			body: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>t</title>
+<atom:link href="https://site.example/index.xml" rel="self"/>
+<link> https://site.example/ </link>
+<item><title>a</title><atom:link href="https://site.example/a.xml" rel="self"/><link>https://site.example/a/</link></item></channel></rss>`,
+			wantSite: "https://site.example/",
+			wantLink: "https://site.example/a/",
+		},
+		{
+			name: "only an atom link",
+			body: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>t</title>
+<atom:link href="https://site.example/index.xml" rel="self"/>
+<item><title>a</title><link>https://site.example/a/</link></item></channel></rss>`,
+			wantSite: "",
+			wantLink: "https://site.example/a/",
+		},
+		{
+			name: "rss 1.0",
+			body: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel rdf:about="https://site.example/"><title>t</title><atom:link href="https://site.example/index.rdf" rel="self"/><link>https://site.example/</link></channel>
+<item rdf:about="https://site.example/a/"><title>a</title><link>https://site.example/a/</link></item></rdf:RDF>`,
+			wantSite: "https://site.example/",
+			wantLink: "https://site.example/a/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := ParseFeed([]byte(tt.body), "")
+			if err != nil {
+				t.Fatalf("ParseFeed: %v", err)
+			}
+			if feed.Link != tt.wantSite {
+				t.Errorf("feed link = %q, want %q", feed.Link, tt.wantSite)
+			}
+			if len(feed.Items) != 1 || feed.Items[0].Link != tt.wantLink {
+				t.Errorf("items = %+v, want one linking to %q", feed.Items, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestParseFeedJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		wantTitle   string
+		wantErr     bool
+	}{
+		{
+			name:      "json feed 1.1",
+			body:      `{"version":"https://jsonfeed.org/version/1.1","title":"j","items":[{"id":"1","title":"a"}]}`,
+			wantTitle: "j",
+		},
+		{
+			name:        "json feed 1.0 as application/json",
+			body:        "\ufeff\n" + `{"version":"https://jsonfeed.org/version/1","title":"j","items":[]}`,
+			contentType: "application/json",
+			wantTitle:   "j",
+		},
+		{
+			name:    "api error",
+			body:    `{"error":"nope"}`,
+			wantErr: true,
+		},
+		{
+			name:        "other version",
+			body:        `{"version":"2.0","title":"j","items":[]}`,
+			contentType: "application/feed+json",
+			wantErr:     true,
+		},
+		{
+			name:        "not json",
+			body:        `{nope`,
+			contentType: "application/json",
+			wantErr:     true,
+		},
+		{
+			name:        "xml served as feed+json",
+			body:        `<rss version="2.0"><channel><title>x</title></channel></rss>`,
+			contentType: "application/feed+json",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := ParseFeed([]byte(tt.body), tt.contentType)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotAFeed) {
+					t.Fatalf("ParseFeed error = %v, want ErrNotAFeed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFeed: %v", err)
+			}
+			if feed.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", feed.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	atom := func(entry string) string {
+		return `<feed xmlns="http://www.w3.org/2005/Atom"><title>f</title>
+<link rel="self" href="https://x.example/feed.atom"/><link href="https://x.example/"/>` + entry + `</feed>`
+	}
+	tests := []struct {
+		name  string
+		entry string
+		want  Item
+	}{
+		{
+			name:  "alternate link over the others",
+			entry: `<entry><id>urn:1</id><link rel="edit" href="https://x.example/api/1"/><link rel="self" href="https://x.example/1.atom"/><link rel="alternate" href="https://x.example/1"/></entry>`,
+			want:  Item{ID: "urn:1", Link: "https://x.example/1"},
+		},
+		{
+			name:  "link without rel is alternate",
+			entry: `<entry><id>urn:1</id><link rel="replies" href="https://x.example/1/comments"/><link href="https://x.example/1"/></entry>`,
+			want:  Item{ID: "urn:1", Link: "https://x.example/1"},
+		},
+		{
+			name:  "html alternate over other types",
+			entry: `<entry><id>urn:1</id><link rel="alternate" type="application/pdf" href="https://x.example/1.pdf"/><link rel="alternate" type="text/html" href="https://x.example/1"/></entry>`,
+			want:  Item{ID: "urn:1", Link: "https://x.example/1"},
+		},
+		{
+			name:  "only an edit link",
+			entry: `<entry><id>urn:1</id><link rel="edit" href="https://x/api/1"/></entry>`,
+			want:  Item{ID: "urn:1"},
+		},
+		{
+			name:  "only self and enclosure links",
+			entry: `<entry><id>urn:1</id><link rel="self" href="https://x/1.atom"/><link rel="enclosure" type="audio/mpeg" length="10" href="https://x/1.mp3"/></entry>`,
+			want: Item{ID: "urn:1", Enclosures: []Enclosure{
+				{URL: "https://x/1.mp3", Type: "audio/mpeg", Length: 10},
+			}},
+		},
+		{
+			name:  "published and updated",
+			entry: `<entry><id> urn:1 </id><published>2015-11-05T10:00:00Z</published><updated>2015-11-06T10:00:00Z</updated></entry>`,
+			want:  Item{ID: "urn:1", Published: "2015-11-05T10:00:00Z", Updated: "2015-11-06T10:00:00Z"},
+		},
+		{
+			name:  "updated only",
+			entry: `<entry><id>urn:1</id><updated>2015-11-06T10:00:00Z</updated></entry>`,
+			want:  Item{ID: "urn:1", Updated: "2015-11-06T10:00:00Z"},
+		},
+		{
+			name:  "summary and content",
+			entry: `<entry><id>urn:1</id><summary>short &amp; sweet</summary><content type="html">&lt;p&gt;long&lt;/p&gt;</content></entry>`,
+			want:  Item{ID: "urn:1", Description: "short &amp; sweet", Content: "<p>long</p>"},
+		},
+		{
+			name:  "content stands in for a missing summary",
+			entry: `<entry><id>urn:1</id><content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>long</p></div></content></entry>`,
+			want:  Item{ID: "urn:1", Description: "<div><p>long</p></div>", Content: "<div><p>long</p></div>"},
+		},
+		{
+			name:  "no id",
+			entry: `<entry><title type="html">a &amp;amp; b</title><link href="https://x.example/1"/></entry>`,
+			want:  Item{Title: "a & b", Link: "https://x.example/1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := ParseFeed([]byte(atom(tt.entry)), "")
+			if err != nil {
+				t.Fatalf("ParseFeed: %v", err)
+			}
+			if feed.Link != "https://x.example/" {
+				t.Errorf("feed link = %q", feed.Link)
+			}
+			if len(feed.Items) != 1 {
+				t.Fatalf("got %d items", len(feed.Items))
+			}
+			got := feed.Items[0]
+			got.base = ""
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("item = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package rss
+
+import (
+	"encoding/xml"
+	"html"
+	"strings"
+)
+
+//rss 1.0 keeps its items as siblings of the channel under rdf:RDF
+type rdfFeed struct {
+	Channel struct {
+		Title       string   `xml:"title"`
+		Links       rssLinks `xml:"link"`
+		Description string   `xml:"description"`
+
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	} `xml:"channel"`
+	Item []rdfItem `xml:"item"`
+}
+
+type rdfItem struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"title"`
+	Links       rssLinks `xml:"link"`
+	Description string   `xml:"description"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+}
+
+func parseRDF(body []byte) (*Feed, error) {
+	var rf rdfFeed
+	err := xml.Unmarshal(body, &rf)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{
+		Title:          html.UnescapeString(rf.Channel.Title),
+		Link:           rf.Channel.Links.String(),
+		Description:    html.UnescapeString(rf.Channel.Description),
+		UpdateInterval: updateInterval("", rf.Channel.UpdatePeriod, rf.Channel.UpdateFrequency),
+	}
+	for _, ri := range rf.Item {
+		item := Item{
+			ID:          strings.TrimSpace(ri.About),
+			Title:       html.UnescapeString(ri.Title),
+			Link:        ri.Links.String(),
+			Description: strings.TrimSpace(ri.Description),
+			Content:     strings.TrimSpace(ri.Content),
+			Published:   strings.TrimSpace(ri.Date), //W3CDTF, handled by ParseDate
+		}
+		if creator := strings.TrimSpace(ri.Creator); creator != "" {
+			item.Authors = append(item.Authors, creator)
+		}
+		item.Categories = uniqueNames(ri.Subjects)
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
@@ -0,0 +1,67 @@
+package rss
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+//sy:updatePeriod values from the RSS 1.0 syndication module
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+type skipHours struct {
+	Hour []string `xml:"hour"`
+}
+
+type skipDays struct {
+	Day []string `xml:"day"`
+}
+
+//the longest of <ttl> (minutes) and sy:updatePeriod divided by sy:updateFrequency
+func updateInterval(ttl, period, frequency string) time.Duration {
+	interval := time.Duration(0)
+	if minutes, err := strconv.Atoi(strings.TrimSpace(ttl)); err == nil && minutes > 0 {
+		interval = time.Duration(minutes) * time.Minute
+	}
+
+	syPeriod, ok := updatePeriods[strings.ToLower(strings.TrimSpace(period))]
+	if !ok {
+		return interval
+	}
+	times, err := strconv.Atoi(strings.TrimSpace(frequency))
+	if err != nil || times < 1 {
+		times = 1
+	}
+	return max(interval, syPeriod/time.Duration(times))
+}
+
+//<skipHours> lists GMT hours 0-23, some publishers write 24 for midnight
+func (sh skipHours) hours() []int {
+	var hours []int
+	for _, value := range sh.Hour {
+		hour, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || hour < 0 || hour > 24 {
+			continue
+		}
+		hours = append(hours, hour%24)
+	}
+	return hours
+}
+
+func (sd skipDays) days() []time.Weekday {
+	var days []time.Weekday
+	for _, value := range sd.Day {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(strings.TrimSpace(value), d.String()) {
+				days = append(days, d)
+			}
+		}
+	}
+	return days
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"context"
 	"github.com/samassembly/gator/internal/database"
 	"github.com/samassembly/gator/internal/config"
@@ -13,6 +15,8 @@ import (
 type state struct {
 	db  *database.Queries
 	cfg *config.Config
+	//for the few commands that need a transaction
+	conn *sql.DB
 }
 
 func main() {
@@ -34,9 +38,10 @@ func main() {
 	defer db.Close()
 	dbQueries := database.New(db)
 	programState.db = dbQueries
+	programState.conn = db
 
 	cmds := commands{
-		registeredCommands: make(map[string]func(*state, command) error),
+		registeredCommands: make(map[string]func(context.Context, *state, command) error),
 	}
 	cmds.register("login", handlerLogin)
 	cmds.register("register", handlerRegister)
@@ -45,10 +50,14 @@ func main() {
 	cmds.register("agg", handlerAgg)
 	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
 	cmds.register("feeds", handlerFeeds)
+	cmds.register("pausefeed", middlewareLoggedIn(handlerPauseFeed))
+	cmds.register("resumefeed", middlewareLoggedIn(handlerResumeFeed))
+	cmds.register("setinterval", middlewareLoggedIn(handlerSetInterval))
 	cmds.register("follow",  middlewareLoggedIn(handlerFollow))
 	cmds.register("following",  middlewareLoggedIn(handlerFollowing))
 	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
 	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
+	cmds.register("download", middlewareLoggedIn(handlerDownload))
 
 	if len(os.Args) < 2 {
 		log.Fatal("Usage: cli <command> [args...]")
@@ -58,19 +67,27 @@ func main() {
 	cmdName := os.Args[1]
 	cmdArgs := os.Args[2:]
 
-	err = cmds.run(programState, command{Name: cmdName, Args: cmdArgs})
+	//cancelled on Ctrl-C or a service stop so long running commands can wind down,
+	//a second signal falls back to the default and kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err = cmds.run(ctx, programState, command{Name: cmdName, Args: cmdArgs})
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
 //dry up handlers that reuse this snippet to get current user
-func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
-	return func(s *state, cmd command) error {
-		user, err := s.db.GetUser(context.Background(), s.cfg.CurrentUserName)
+func middlewareLoggedIn(handler func(ctx context.Context, s *state, cmd command, user database.User) error) func(context.Context, *state, command) error {
+	return func(ctx context.Context, s *state, cmd command) error {
+		user, err := s.db.GetUser(ctx, s.cfg.CurrentUserName)
 		if err != nil {
 			return err
 		}
-		return handler(s, cmd, user)
+		return handler(ctx, s, cmd, user)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/samassembly/gator/internal/database"
+	"github.com/samassembly/gator/internal/rss"
+)
+
+const (
+	//publish times looked at when learning how often a feed posts
+	cadenceSample = 20
+	//a learned interval never stretches past this
+	maxLearnedInterval = 24 * time.Hour
+	//skipHours and skipDays with every bit set
+	allHours = 1<<24 - 1
+	allDays  = 1<<7 - 1
+)
+
+//publisher hints in effect for a feed, fresh from the document after a full
+//fetch and the stored ones after a 304 or a failure
+type scheduleHints struct {
+	interval  time.Duration
+	skipHours int32 //bit n set skips hour n GMT
+	skipDays  int32 //bit n set skips time.Weekday(n)
+	maxAge    time.Duration
+}
+
+func storedHints(feed database.Feed) scheduleHints {
+	return scheduleHints{
+		interval:  time.Duration(feed.HintIntervalSeconds) * time.Second,
+		skipHours: feed.SkipHours,
+		skipDays:  feed.SkipDays,
+	}
+}
+
+func documentHints(feedData *rss.Feed) scheduleHints {
+	hints := scheduleHints{interval: feedData.UpdateInterval}
+	for _, hour := range feedData.SkipHours {
+		hints.skipHours |= 1 << hour
+	}
+	for _, day := range feedData.SkipDays {
+		hints.skipDays |= 1 << int(day)
+	}
+	return hints
+}
+
+//how long a feed rests after a successful fetch, a user set interval wins,
+//otherwise the cadence learned from its posts and the publisher's hints
+func fetchInterval(base time.Duration, feed database.Feed, hints scheduleHints, published []time.Time) time.Duration {
+	if feed.FetchIntervalSeconds.Valid {
+		return time.Duration(feed.FetchIntervalSeconds.Int32) * time.Second
+	}
+	interval := max(base, learnedInterval(published))
+	//publishers may ask to be polled less often, never more
+	return max(interval, hints.interval, hints.maxAge)
+}
+
+//half the median gap between recent posts, so a new post usually waits at
+//most half its cadence, zero until there are enough posts to tell
+func learnedInterval(published []time.Time) time.Duration {
+	if len(published) < 3 {
+		return 0
+	}
+	sorted := slices.Clone(published)
+	slices.SortFunc(sorted, func(a, b time.Time) int { return a.Compare(b) })
+
+	gaps := make([]time.Duration, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		gaps = append(gaps, sorted[i].Sub(sorted[i-1]))
+	}
+	slices.Sort(gaps)
+	return min(gaps[len(gaps)/2]/2, maxLearnedInterval)
+}
+
+//double the interval for each consecutive failure, up to maxBackoff
+func backoffInterval(interval time.Duration, failures int32) time.Duration {
+	backoff := interval
+	for i := int32(0); i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return max(interval, min(backoff, maxBackoff))
+}
+
+//delay until the first moment after the interval that is not in a skipped hour or day,
+//hints that skip every hour or every day can't be honored and are ignored
+func nextFetchDelay(now time.Time, interval time.Duration, hints scheduleHints) time.Duration {
+	next := now.UTC().Add(interval)
+	if hints.skipHours&allHours == allHours || hints.skipDays&allDays == allDays {
+		return interval
+	}
+	for i := 0; i < 7*24; i++ {
+		if hints.skipHours&(1<<next.Hour()) == 0 && hints.skipDays&(1<<int(next.Weekday())) == 0 {
+			break
+		}
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next.Sub(now)
+}
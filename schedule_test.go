@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLearnedInterval(t *testing.T) {
+	start := time.Date(2015, 11, 5, 10, 0, 0, 0, time.UTC)
+	//publish times at the given offsets from start, in any order
+	at := func(offsets ...time.Duration) []time.Time {
+		var times []time.Time
+		for _, offset := range offsets {
+			times = append(times, start.Add(offset))
+		}
+		return times
+	}
+	tests := []struct {
+		name      string
+		published []time.Time
+		want      time.Duration
+	}{
+		{"no posts", nil, 0},
+		{"too few posts", at(0, time.Hour), 0},
+		{"even cadence", at(0, 4*time.Hour, 8*time.Hour, 12*time.Hour), 2 * time.Hour},
+		{"unsorted", at(12*time.Hour, 0, 8*time.Hour, 4*time.Hour), 2 * time.Hour},
+		//gaps of 1h, 2h and 30h, the outlier doesn't move the median
+		{"median gap", at(0, time.Hour, 3*time.Hour, 33*time.Hour), time.Hour},
+		//gaps of 1h, 1h, 10h and 10h, the upper median is taken
+		{"even number of gaps", at(0, time.Hour, 2*time.Hour, 12*time.Hour, 22*time.Hour), 5 * time.Hour},
+		{"same time", at(0, 0, 0), 0},
+		{"capped", at(0, 72*time.Hour, 144*time.Hour), maxLearnedInterval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := learnedInterval(tt.published); got != tt.want {
+				t.Errorf("learnedInterval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffInterval(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		failures int32
+		want     time.Duration
+	}{
+		{time.Hour, 0, time.Hour},
+		{time.Hour, 1, 2 * time.Hour},
+		{time.Hour, 3, 8 * time.Hour},
+		{time.Hour, 5, maxBackoff},
+		{time.Hour, 1000, maxBackoff},
+		{10 * time.Minute, 4, 160 * time.Minute},
+		//an interval already past the cap is not cut short
+		{48 * time.Hour, 2, 48 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoffInterval(tt.interval, tt.failures); got != tt.want {
+			t.Errorf("backoffInterval(%v, %d) = %v, want %v", tt.interval, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestNextFetchDelay(t *testing.T) {
+	//a thursday
+	now := time.Date(2015, 11, 5, 10, 30, 0, 0, time.UTC)
+	hours := func(hours ...int) int32 {
+		var mask int32
+		for _, hour := range hours {
+			mask |= 1 << hour
+		}
+		return mask
+	}
+	days := func(days ...time.Weekday) int32 {
+		var mask int32
+		for _, day := range days {
+			mask |= 1 << int(day)
+		}
+		return mask
+	}
+	tests := []struct {
+		name     string
+		interval time.Duration
+		hints    scheduleHints
+		want     time.Duration
+	}{
+		{"no hints", time.Hour, scheduleHints{}, time.Hour},
+		{"hour not skipped", time.Hour, scheduleHints{skipHours: hours(3, 10)}, time.Hour},
+		//11:30 is skipped, so the fetch waits for the top of 12:00
+		{"skipped hour", time.Hour, scheduleHints{skipHours: hours(11)}, 90 * time.Minute},
+		{"run of skipped hours", time.Hour, scheduleHints{skipHours: hours(11, 12, 13)}, 210 * time.Minute},
+		{"skipped hours across midnight", 13 * time.Hour, scheduleHints{skipHours: hours(23, 0, 1)}, 15*time.Hour + 30*time.Minute},
+		//thursday and friday are skipped, saturday starts 37h30m later
+		{"skipped days", time.Hour, scheduleHints{skipDays: days(time.Thursday, time.Friday)}, 37*time.Hour + 30*time.Minute},
+		{"skipped hour on the next free day", time.Hour, scheduleHints{skipHours: hours(0), skipDays: days(time.Thursday)}, 14*time.Hour + 30*time.Minute},
+		{"every hour skipped", time.Hour, scheduleHints{skipHours: allHours}, time.Hour},
+		{"every day skipped", 2 * time.Hour, scheduleHints{skipDays: allDays}, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextFetchDelay(now, tt.interval, tt.hints); got != tt.want {
+				t.Errorf("nextFetchDelay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	//skipHours are gmt whatever zone the clock is in
+	local := now.In(time.FixedZone("UTC+5", 5*60*60))
+	if got := nextFetchDelay(local, time.Hour, scheduleHints{skipHours: hours(11)}); got != 90*time.Minute {
+		t.Errorf("nextFetchDelay from another zone = %v, want 1h30m", got)
+	}
+}
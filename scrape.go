@@ -0,0 +1,623 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/samassembly/gator/internal/database"
+	"github.com/samassembly/gator/internal/rss"
+)
+
+const (
+	//how many claimed feeds may wait for each worker
+	queuePerWorker = 4
+	//upper bound on fetching and storing a single feed
+	feedTimeout = 30 * time.Second
+	//long enough for a claimed feed to wait out a full queue and its own fetch
+	leaseDuration = (queuePerWorker + 1) * feedTimeout * 2
+	//failing feeds wait twice as long after each failure, up to this long
+	maxBackoff = 24 * time.Hour
+	//consecutive failures before a feed is deactivated, unless configured
+	defaultDeadFeedFailures = 15
+	//how often deactivated feeds are probed for a comeback
+	deadFeedProbe = 7 * 24 * time.Hour
+)
+
+//values of feeds.status
+const (
+	feedStatusActive = "active"
+	feedStatusPaused = "paused"
+	feedStatusDead   = "dead"
+)
+
+//running totals for one agg run, shared by the workers
+type aggStats struct {
+	mu           sync.Mutex
+	fetched      int
+	notModified  int
+	failed       int
+	rateLimited  int
+	interrupted  int
+	newPosts     int
+	updatedPosts int
+}
+
+func (st *aggStats) record(result scrapeResult, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var limited *rss.RateLimitedError
+	switch {
+	case errors.Is(err, context.Canceled):
+		st.interrupted++
+	case errors.As(err, &limited):
+		st.rateLimited++
+	case err != nil:
+		st.failed++
+	case result.notModified:
+		st.notModified++
+	default:
+		st.fetched++
+	}
+	st.newPosts += result.newPosts
+	st.updatedPosts += result.updatedPosts
+}
+
+func (st *aggStats) summary(elapsed time.Duration) string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return fmt.Sprintf("Collected for %s: %d feeds fetched, %d not modified, %d failed, %d rate limited, %d interrupted, %d new posts, %d updated posts",
+		elapsed.Round(time.Second), st.fetched, st.notModified, st.failed, st.rateLimited, st.interrupted, st.newPosts, st.updatedPosts)
+}
+
+type scrapeResult struct {
+	notModified  bool
+	found        int
+	newPosts     int
+	updatedPosts int
+	hints        scheduleHints
+	movedTo      string
+}
+
+//one agg run, claimed feeds go through a bounded queue to a pool of workers
+type aggregator struct {
+	db            *database.Queries
+	conn          *sql.DB
+	fetcher       *rss.Fetcher
+	interval      time.Duration
+	deadThreshold int32
+	queue         chan database.Feed
+	stats         aggStats
+	wg            sync.WaitGroup
+}
+
+func newAggregator(db *database.Queries, conn *sql.DB, fetcher *rss.Fetcher, interval time.Duration, workers int, deadThreshold int) *aggregator {
+	return &aggregator{
+		db:            db,
+		conn:          conn,
+		fetcher:       fetcher,
+		interval:      interval,
+		deadThreshold: int32(deadThreshold),
+		queue:         make(chan database.Feed, workers*queuePerWorker),
+	}
+}
+
+func (a *aggregator) startWorkers(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.feedWorker(ctx)
+		}()
+	}
+}
+
+//stop handing out feeds and wait for the workers to drain the queue
+func (a *aggregator) stop() {
+	close(a.queue)
+	a.wg.Wait()
+}
+
+//claim feeds due for a fetch, as many as the queue has room for
+func (a *aggregator) scrapeFeeds(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	free := cap(a.queue) - len(a.queue)
+	if free == 0 {
+		log.Println("Feed queue is full, workers are falling behind")
+		return
+	}
+
+	//the lease keeps other aggregator processes off these feeds, and lapses
+	//on its own if this process dies before releasing it
+	feeds, err := a.db.ClaimNextFeedsToFetch(ctx, database.ClaimNextFeedsToFetchParams{
+		MaxFeeds:     int32(free),
+		LeaseSeconds: int32(leaseDuration.Seconds()),
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Println("Couldn't get next feeds to fetch", err)
+		}
+		return
+	}
+
+	for _, feed := range feeds {
+		a.queue <- feed
+	}
+	if len(feeds) > 0 {
+		log.Printf("Found %d feeds to fetch!", len(feeds))
+	}
+}
+
+func (a *aggregator) feedWorker(ctx context.Context) {
+	//bookkeeping has to reach the database even after shutdown has begun
+	cleanupCtx := context.WithoutCancel(ctx)
+
+	for feed := range a.queue {
+		if ctx.Err() != nil {
+			//shutting down, hand queued feeds back to the other aggregators
+			a.releaseFeed(cleanupCtx, feed)
+			continue
+		}
+
+		feedCtx, cancel := context.WithTimeout(ctx, feedTimeout)
+		result, err := scrapeFeed(feedCtx, a.db, a.conn, a.fetcher, feed)
+		cancel()
+
+		if ctx.Err() != nil {
+			//interrupted part way, whatever was stored stays and the rest is picked up next run
+			log.Printf("Feed %s interrupted by shutdown", feed.Name)
+			a.releaseFeed(cleanupCtx, feed)
+			a.stats.record(result, context.Canceled)
+			continue
+		}
+		a.stats.record(result, err)
+		var delay time.Duration
+		var limited *rss.RateLimitedError
+		if errors.As(err, &limited) {
+			//the host asked for a break or had too many feeds queued for it,
+			//neither says anything about the feed's health
+			log.Printf("Feed %s rate limited: %v", feed.Name, err)
+			delay = a.fetchDelay(cleanupCtx, feed, result.hints, feed.ConsecutiveFailures, feed.Status)
+			delay = max(delay, limited.RetryAfter)
+		} else {
+			failures, status := a.recordFeedHealth(cleanupCtx, feed, err)
+			delay = a.fetchDelay(cleanupCtx, feed, result.hints, failures, status)
+		}
+
+		//marking the feed fetched also releases its lease
+		_, err = a.db.MarkFeedFetched(cleanupCtx, database.MarkFeedFetchedParams{
+			DelaySeconds: int32(delay.Seconds()),
+			ID:           feed.ID,
+		})
+		if err != nil {
+			log.Printf("Couldn't mark feed %s fetched: %v", feed.Name, err)
+		}
+
+		if result.movedTo != "" {
+			err = a.moveFeed(cleanupCtx, feed, result.movedTo)
+			if err != nil {
+				log.Printf("Couldn't move feed %s to %s: %v", feed.Name, result.movedTo, err)
+			}
+		}
+	}
+}
+
+//point a permanently redirected feed at its new url, when the new url is a
+//feed of its own already the two are merged into that one
+func (a *aggregator) moveFeed(ctx context.Context, feed database.Feed, newURL string) error {
+	tx, err := a.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := a.db.WithTx(tx)
+
+	target, err := qtx.GetFeed(ctx, newURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = qtx.SetFeedURL(ctx, database.SetFeedURLParams{
+			ID:  feed.ID,
+			Url: newURL,
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Feed %s moved permanently from %s to %s", feed.Name, feed.Url, newURL)
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	//followers and posts the target lacks are carried over, the rest goes with the old feed
+	err = qtx.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.MovePosts(ctx, database.MovePostsParams{
+		ToFeedID:   target.ID,
+		FromFeedID: feed.ID,
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.DeleteFeed(ctx, feed.ID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Feed %s moved permanently from %s to %s and was merged into %s", feed.Name, feed.Url, newURL, target.Name)
+	return tx.Commit()
+}
+
+//store the outcome of a fetch, returns the feed's failure count and status afterwards
+func (a *aggregator) recordFeedHealth(ctx context.Context, feed database.Feed, fetchErr error) (int32, string) {
+	if fetchErr == nil {
+		status, err := a.db.RecordFeedSuccess(ctx, feed.ID)
+		if err != nil {
+			log.Printf("Couldn't record success of feed %s: %v", feed.Name, err)
+			return 0, feed.Status
+		}
+		if feed.Status == feedStatusDead && status == feedStatusActive {
+			log.Printf("Feed %s is reachable again and has been reactivated", feed.Name)
+		}
+		return 0, status
+	}
+
+	health, err := a.db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		LastError: sql.NullString{
+			String: fetchErr.Error(),
+			Valid:  true,
+		},
+		DeadThreshold: a.deadThreshold,
+		ID:            feed.ID,
+	})
+	if err != nil {
+		log.Printf("Couldn't record failure of feed %s: %v", feed.Name, err)
+		return feed.ConsecutiveFailures + 1, feed.Status
+	}
+	if feed.Status == feedStatusActive && health.Status == feedStatusDead {
+		log.Printf("Feed %s failed %d times in a row and has been deactivated: %v", feed.Name, health.ConsecutiveFailures, fetchErr)
+		return health.ConsecutiveFailures, health.Status
+	}
+	log.Printf("Couldn't collect feed %s (%d failures in a row, backing off): %v", feed.Name, health.ConsecutiveFailures, fetchErr)
+	return health.ConsecutiveFailures, health.Status
+}
+
+//how long until the feed is due again, dead feeds are only probed now and then
+//and failing ones back off from their usual interval
+func (a *aggregator) fetchDelay(ctx context.Context, feed database.Feed, hints scheduleHints, failures int32, status string) time.Duration {
+	if status == feedStatusDead {
+		return deadFeedProbe
+	}
+	rows, err := a.db.GetRecentPublishTimes(ctx, database.GetRecentPublishTimesParams{
+		FeedID: feed.ID,
+		Limit:  cadenceSample,
+	})
+	if err != nil {
+		log.Printf("Couldn't get recent posts of feed %s: %v", feed.Name, err)
+	}
+	published := make([]time.Time, 0, len(rows))
+	for _, row := range rows {
+		if row.Valid {
+			published = append(published, row.Time)
+		}
+	}
+
+	interval := fetchInterval(a.interval, feed, hints, published)
+	if failures > 0 {
+		interval = backoffInterval(interval, failures)
+	}
+	return nextFetchDelay(time.Now(), interval, hints)
+}
+
+func (a *aggregator) releaseFeed(ctx context.Context, feed database.Feed) {
+	err := a.db.ReleaseFeedLease(ctx, feed.ID)
+	if err != nil {
+		log.Printf("Couldn't release lease on feed %s: %v", feed.Name, err)
+	}
+}
+
+func scrapeFeed(ctx context.Context, db *database.Queries, conn *sql.DB, fetcher *rss.Fetcher, feed database.Feed) (scrapeResult, error) {
+	//until the document says otherwise the hints stored last time apply
+	result := scrapeResult{hints: storedHints(feed)}
+	cache := rss.CacheHeaders{
+		ETag:         feed.Etag.String,
+		LastModified: feed.LastModified.String,
+	}
+	feedData, info, err := fetcher.Fetch(ctx, feed.Url, cache)
+	result.hints.maxAge = info.MaxAge
+	if err != nil && !errors.Is(err, rss.ErrNotModified) {
+		return result, err
+	}
+	//only a redirect that led somewhere working is worth following
+	if info.MovedTo != feed.Url {
+		result.movedTo = info.MovedTo
+	}
+	if errors.Is(err, rss.ErrNotModified) {
+		log.Printf("Feed %s not modified since last fetch", feed.Name)
+		result.notModified = true
+		return result, nil
+	}
+	newCache := info.Cache
+	feedURL := info.FinalURL
+	if feedURL == "" {
+		feedURL = feed.Url
+	}
+	feedData.ResolveLinks(feedURL)
+
+	//once the body is in hand each write runs to completion, shutdown is
+	//only checked between posts
+	writeCtx := context.WithoutCancel(ctx)
+	hints := documentHints(feedData)
+	hints.maxAge = info.MaxAge
+	if hints != result.hints {
+		err = db.SetFeedScheduleHints(writeCtx, database.SetFeedScheduleHintsParams{
+			ID:                  feed.ID,
+			HintIntervalSeconds: int32(hints.interval.Seconds()),
+			SkipHours:           hints.skipHours,
+			SkipDays:            hints.skipDays,
+		})
+		if err != nil {
+			log.Printf("Couldn't store schedule hints for feed %s: %v", feed.Name, err)
+		}
+	}
+	result.hints = hints
+	result.found = len(feedData.Items)
+	stored := true
+	for _, item := range feedData.Items {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		outcome, err := storePostTx(writeCtx, db, conn, feed.ID, item)
+		if err != nil {
+			log.Printf("Couldn't store post %s: %v", item.Title, err)
+			stored = false
+			continue
+		}
+		switch outcome {
+		case postNew:
+			result.newPosts++
+		case postUpdated:
+			result.updatedPosts++
+		}
+	}
+
+	//the validators only stand for posts that are all stored, otherwise the
+	//next fetch would get a 304 and never see the missing ones again
+	if stored && newCache != cache {
+		err = db.SetFeedCacheHeaders(writeCtx, database.SetFeedCacheHeadersParams{
+			ID: feed.ID,
+			Etag: sql.NullString{
+				String: newCache.ETag,
+				Valid:  newCache.ETag != "",
+			},
+			LastModified: sql.NullString{
+				String: newCache.LastModified,
+				Valid:  newCache.LastModified != "",
+			},
+		})
+		if err != nil {
+			log.Printf("Couldn't store cache headers for feed %s: %v", feed.Name, err)
+		}
+	}
+	log.Printf("Feed %s collected, %v posts found, %v new, %v updated", feed.Name, result.found, result.newPosts, result.updatedPosts)
+	return result, nil
+}
+
+//what storePost did with an item
+type postOutcome int
+
+const (
+	postUnchanged postOutcome = iota
+	postNew
+	postUpdated
+)
+
+//storePost in a transaction of its own, a post whose enclosures, authors or
+//categories fail to go in is not left stored with the new content hash, which
+//would keep the next fetch from trying again
+func storePostTx(ctx context.Context, db *database.Queries, conn *sql.DB, feedID uuid.UUID, item rss.Item) (postOutcome, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return postUnchanged, err
+	}
+	defer tx.Rollback()
+	outcome, err := storePost(ctx, db.WithTx(tx), feedID, item)
+	if err != nil {
+		return postUnchanged, err
+	}
+	return outcome, tx.Commit()
+}
+
+//insert an item as a post or update the stored one when it changed, the
+//statements belong together so db should be bound to a transaction
+func storePost(ctx context.Context, db *database.Queries, feedID uuid.UUID, item rss.Item) (postOutcome, error) {
+	//fmt.Printf("Found post: %s\n", item.Title)
+	pubDate := item.Published
+	if pubDate == "" {
+		pubDate = item.Updated
+	}
+	//posts without a usable date are placed at the time we first saw them
+	publishedAt := sql.NullTime{
+		Time:  time.Now().UTC(),
+		Valid: true,
+	}
+	if t, err := rss.ParseDate(pubDate); err == nil {
+		publishedAt.Time = t
+	} else if pubDate != "" {
+		log.Printf("Couldn't parse date of post %s: %v", item.Title, err)
+	}
+	//updated_at follows the publisher's <updated> when there is one
+	updatedAt := time.Now().UTC()
+	if t, err := rss.ParseDate(item.Updated); err == nil {
+		updatedAt = t
+	}
+
+	guid := item.GUID()
+	contentHash := sql.NullString{
+		String: item.ContentHash(),
+		Valid:  true,
+	}
+	//posts stored before guids were tracked got the link and title fallback,
+	//the first time their item shows up again they take its real guid
+	err := db.AdoptLegacyPost(ctx, database.AdoptLegacyPostParams{
+		Guid:   guid,
+		FeedID: feedID,
+		Url:    item.Link,
+	})
+	if err != nil {
+		return postUnchanged, fmt.Errorf("Could not match post to its earlier copy: %v", err)
+	}
+
+	//a post stored before content hashes were kept takes this version as its
+	//own, it is not counted as updated since nothing says it changed
+	legacyID, err := db.BackfillPostHash(ctx, database.BackfillPostHashParams{
+		Title: item.Title,
+		Url:   item.Link,
+		Description: sql.NullString{
+			String: item.Description,
+			Valid:  true,
+		},
+		Content: sql.NullString{
+			String: item.Content,
+			Valid:  item.Content != "",
+		},
+		ContentHash: contentHash,
+		FeedID:      feedID,
+		Guid:        guid,
+	})
+	if err == nil {
+		err = storeEnclosures(ctx, db, legacyID, item.Enclosures, true)
+		if err != nil {
+			return postUnchanged, err
+		}
+		return postUnchanged, storeAuthorsAndCategories(ctx, db, legacyID, item, true)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return postUnchanged, err
+	}
+
+	//the stored version is kept as a revision by the same statement that
+	//overwrites it
+	id := uuid.New()
+	post, err := db.CreatePost(ctx, database.CreatePostParams{
+		ID:        id,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: updatedAt,
+		FeedID:    feedID,
+		Title:     item.Title,
+		Description: sql.NullString{
+			String: item.Description,
+			Valid:  true,
+		},
+		Url:         item.Link,
+		PublishedAt: publishedAt,
+		Guid:        guid,
+		ContentHash: contentHash,
+		Content: sql.NullString{
+			String: item.Content,
+			Valid:  item.Content != "",
+		},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		//already stored and unchanged
+		return postUnchanged, nil
+	}
+	if err != nil {
+		return postUnchanged, err
+	}
+	outcome := postNew
+	if post.ID != id {
+		outcome = postUpdated
+	}
+
+	err = storeEnclosures(ctx, db, post.ID, item.Enclosures, outcome == postUpdated)
+	if err != nil {
+		return outcome, err
+	}
+	err = storeAuthorsAndCategories(ctx, db, post.ID, item, outcome == postUpdated)
+	if err != nil {
+		return outcome, err
+	}
+	return outcome, nil
+}
+
+//attach an item's enclosures to its post, an updated post drops the ones it had
+func storeEnclosures(ctx context.Context, db *database.Queries, postID uuid.UUID, enclosures []rss.Enclosure, replace bool) error {
+	if replace {
+		err := db.DeletePostEnclosures(ctx, postID)
+		if err != nil {
+			return err
+		}
+	}
+	for _, enclosure := range enclosures {
+		err := db.CreatePostEnclosure(ctx, database.CreatePostEnclosureParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			PostID:    postID,
+			Url:       enclosure.URL,
+			MimeType: sql.NullString{
+				String: enclosure.Type,
+				Valid:  enclosure.Type != "",
+			},
+			Length: sql.NullInt64{
+				Int64: enclosure.Length,
+				Valid: enclosure.Length > 0,
+			},
+			DurationSeconds: sql.NullInt32{
+				Int32: int32(enclosure.Duration.Seconds()),
+				Valid: enclosure.Duration > 0,
+			},
+			Thumbnail: enclosure.Thumbnail,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//link a post to the authors and categories of its item, creating the ones
+//never seen before, an updated post drops the links it had
+func storeAuthorsAndCategories(ctx context.Context, db *database.Queries, postID uuid.UUID, item rss.Item, replace bool) error {
+	if replace {
+		err := db.DeletePostAuthors(ctx, postID)
+		if err != nil {
+			return err
+		}
+		err = db.DeletePostCategories(ctx, postID)
+		if err != nil {
+			return err
+		}
+	}
+	for _, author := range item.Authors {
+		err := db.AddPostAuthor(ctx, database.AddPostAuthorParams{
+			ID:     uuid.New(),
+			Name:   author,
+			PostID: postID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, category := range item.Categories {
+		err := db.AddPostCategory(ctx, database.AddPostCategoryParams{
+			ID:     uuid.New(),
+			Name:   category,
+			PostID: postID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+-- name: AddPostAuthor :exec
+WITH author AS (
+    INSERT INTO authors (id, name)
+    VALUES (sqlc.arg(id), sqlc.arg(name))
+    ON CONFLICT (name) DO UPDATE
+    SET name = EXCLUDED.name
+    RETURNING id
+)
+INSERT INTO post_authors (post_id, author_id)
+SELECT sqlc.arg(post_id)::uuid, author.id FROM author
+ON CONFLICT DO NOTHING;
+
+-- name: DeletePostAuthors :exec
+DELETE FROM post_authors
+WHERE post_id = $1;
+
+-- name: GetAuthorsForPosts :many
+SELECT post_authors.post_id, authors.name FROM post_authors
+JOIN authors ON authors.id = post_authors.author_id
+WHERE post_authors.post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY post_authors.post_id, authors.name;
\ No newline at end of file
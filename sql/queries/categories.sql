@@ -0,0 +1,21 @@
+-- name: AddPostCategory :exec
+WITH category AS (
+    INSERT INTO categories (id, name)
+    VALUES (sqlc.arg(id), sqlc.arg(name))
+    ON CONFLICT (name) DO UPDATE
+    SET name = EXCLUDED.name
+    RETURNING id
+)
+INSERT INTO post_categories (post_id, category_id)
+SELECT sqlc.arg(post_id)::uuid, category.id FROM category
+ON CONFLICT DO NOTHING;
+
+-- name: DeletePostCategories :exec
+DELETE FROM post_categories
+WHERE post_id = $1;
+
+-- name: GetCategoriesForPosts :many
+SELECT post_categories.post_id, categories.name FROM post_categories
+JOIN categories ON categories.id = post_categories.category_id
+WHERE post_categories.post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY post_categories.post_id, categories.name;
\ No newline at end of file
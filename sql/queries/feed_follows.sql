@@ -28,4 +28,12 @@ WHERE feed_follows.user_id = $1;
 -- name: Unfollow :exec
 DELETE FROM feed_follows
 WHERE feed_follows.user_id = $1
-AND feed_follows.feed_id = $2;
\ No newline at end of file
+AND feed_follows.feed_id = $2;
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(to_feed_id), updated_at = NOW()
+WHERE feed_id = sqlc.arg(from_feed_id)
+AND user_id NOT IN (
+    SELECT user_id FROM feed_follows WHERE feed_id = sqlc.arg(to_feed_id)
+);
\ No newline at end of file
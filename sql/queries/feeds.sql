@@ -1,12 +1,14 @@
 -- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, site_url, description)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7,
+    $8
 )
 RETURNING *;
 
@@ -15,7 +17,14 @@ SELECT
     feeds.name AS feed_name,
     feeds.url,
     feeds.user_id,
-    users.name AS user_name
+    users.name AS user_name,
+    feeds.last_fetched_at,
+    feeds.consecutive_failures,
+    feeds.last_error,
+    feeds.last_success_at,
+    feeds.status,
+    feeds.fetch_interval_seconds,
+    feeds.next_fetch_at
 FROM 
     feeds
 JOIN 
@@ -28,11 +37,101 @@ WHERE url = $1;
 -- name: MarkFeedFetched :one
 UPDATE feeds
 SET last_fetched_at = NOW(),
+next_fetch_at = NOW() + sqlc.arg(delay_seconds)::int * INTERVAL '1 second',
+locked_until = NULL,
 updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ClaimNextFeedsToFetch :many
+WITH claimed AS (
+    SELECT id FROM feeds
+    WHERE status IN ('active', 'dead')
+    AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    AND (locked_until IS NULL OR locked_until < NOW())
+    ORDER BY next_fetch_at ASC NULLS FIRST
+    LIMIT sqlc.arg(max_feeds)
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE feeds
+SET locked_until = NOW() + sqlc.arg(lease_seconds)::int * INTERVAL '1 second'
+FROM claimed
+WHERE feeds.id = claimed.id
+RETURNING feeds.*;
+
+-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+last_error = sqlc.arg(last_error),
+status = CASE
+    WHEN status = 'active' AND consecutive_failures + 1 >= sqlc.arg(dead_threshold)::int THEN 'dead'
+    ELSE status
+END
+WHERE id = sqlc.arg(id)
+RETURNING consecutive_failures, status;
+
+-- name: RecordFeedSuccess :one
+UPDATE feeds
+SET consecutive_failures = 0,
+last_error = NULL,
+last_success_at = NOW(),
+status = CASE WHEN status = 'dead' THEN 'active' ELSE status END
 WHERE id = $1
+RETURNING status;
+
+-- name: ReleaseFeedLease :exec
+UPDATE feeds
+SET locked_until = NULL
+WHERE id = $1;
+
+-- name: SetFeedFetchInterval :one
+UPDATE feeds
+SET fetch_interval_seconds = $2,
+next_fetch_at = NULL,
+updated_at = NOW()
+WHERE url = $1
+-- feeds are shared, only the user who added a feed or one following it may change it
+AND (user_id = $3 OR EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = $3
+))
 RETURNING *;
 
--- name: GetNextFeedToFetch :one
-SELECT * FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1;
\ No newline at end of file
+-- name: SetFeedScheduleHints :exec
+UPDATE feeds
+SET hint_interval_seconds = $2,
+skip_hours = $3,
+skip_days = $4
+WHERE id = $1;
+
+-- name: SetFeedStatus :one
+UPDATE feeds
+SET status = $2,
+consecutive_failures = CASE WHEN $2 = 'active' THEN 0 ELSE consecutive_failures END,
+next_fetch_at = CASE WHEN $2 = 'active' THEN NULL ELSE next_fetch_at END,
+updated_at = NOW()
+WHERE url = $1
+-- feeds are shared, only the user who added a feed or one following it may change it
+AND (user_id = $3 OR EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id
+    AND feed_follows.user_id = $3
+))
+RETURNING *;
+
+-- name: SetFeedCacheHeaders :exec
+UPDATE feeds
+SET etag = $2,
+last_modified = $3,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetFeedURL :exec
+UPDATE feeds
+SET url = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,37 @@
+-- name: CreatePostEnclosure :exec
+INSERT INTO post_enclosures (id, created_at, post_id, url, mime_type, length, duration_seconds, thumbnail)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+ON CONFLICT (post_id, url) DO UPDATE
+SET mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    thumbnail = EXCLUDED.thumbnail;
+
+-- name: DeletePostEnclosures :exec
+DELETE FROM post_enclosures
+WHERE post_id = $1;
+
+-- name: GetEnclosuresForPosts :many
+SELECT * FROM post_enclosures
+WHERE post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY post_id, thumbnail, created_at;
+
+-- name: GetEnclosuresToDownload :many
+SELECT post_enclosures.*, posts.title AS post_title, feeds.name AS feed_name
+FROM post_enclosures
+JOIN posts ON post_enclosures.post_id = posts.id
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1
+AND NOT post_enclosures.thumbnail
+ORDER BY posts.published_at DESC
+LIMIT $2;
\ No newline at end of file
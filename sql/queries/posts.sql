@@ -1,5 +1,15 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+WITH revision AS (
+    -- keep the stored version around before it is overwritten, in the same
+    -- statement so a failed upsert leaves no revision behind
+    INSERT INTO post_revisions (id, created_at, post_id, title, url, description, revised_at, content)
+    SELECT gen_random_uuid(), NOW(), posts.id, posts.title, posts.url, posts.description, posts.updated_at, posts.content
+    FROM posts
+    WHERE posts.feed_id = $8
+    AND posts.guid = $9
+    AND posts.content_hash IS DISTINCT FROM $10
+)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, content)
 VALUES (
     $1,
     $2,
@@ -8,14 +18,79 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10,
+    $11
 )
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+url = EXCLUDED.url,
+description = EXCLUDED.description,
+content = EXCLUDED.content,
+content_hash = EXCLUDED.content_hash,
+updated_at = EXCLUDED.updated_at
+WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
 RETURNING *;
 
+-- name: GetRecentPublishTimes :many
+SELECT published_at FROM posts
+WHERE feed_id = $1
+AND published_at IS NOT NULL
+ORDER BY published_at DESC
+LIMIT $2;
+
 -- name: GetPostsForUser :many
 SELECT posts.*, feeds.name AS feed_name FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 JOIN feeds ON posts.feed_id = feeds.id
-WHERE feed_follows.user_id = $1
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(author)::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_authors
+    JOIN authors ON authors.id = post_authors.author_id
+    WHERE post_authors.post_id = posts.id
+    AND strpos(lower(authors.name), lower(sqlc.narg(author))) > 0
+))
+AND (sqlc.narg(category)::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_categories
+    JOIN categories ON categories.id = post_categories.category_id
+    WHERE post_categories.post_id = posts.id
+    AND lower(categories.name) = lower(sqlc.narg(category))
+))
 ORDER BY posts.published_at DESC
-LIMIT $2;
\ No newline at end of file
+LIMIT sqlc.arg(max_posts);
+
+-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = sqlc.arg(to_feed_id)
+WHERE feed_id = sqlc.arg(from_feed_id)
+AND guid NOT IN (
+    SELECT guid FROM posts WHERE feed_id = sqlc.arg(to_feed_id)
+);
+
+-- name: AdoptLegacyPost :exec
+UPDATE posts
+SET guid = sqlc.arg(guid)
+WHERE feed_id = sqlc.arg(feed_id)
+AND url = sqlc.arg(url)
+AND content_hash IS NULL
+AND guid <> sqlc.arg(guid)
+AND NOT EXISTS (
+    SELECT 1 FROM posts AS adopted
+    WHERE adopted.feed_id = sqlc.arg(feed_id)
+    AND adopted.guid = sqlc.arg(guid)
+);
+
+-- name: BackfillPostHash :one
+-- posts stored before content hashes were kept take the current version as
+-- they are, nothing says it changed so no revision is kept and updated_at stays
+UPDATE posts
+SET title = sqlc.arg(title),
+url = sqlc.arg(url),
+description = sqlc.arg(description),
+content = sqlc.arg(content),
+content_hash = sqlc.arg(content_hash)
+WHERE feed_id = sqlc.arg(feed_id)
+AND guid = sqlc.arg(guid)
+AND content_hash IS NULL
+RETURNING id;
\ No newline at end of file
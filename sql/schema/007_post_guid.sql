@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE posts DROP CONSTRAINT posts_title_key;
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+ALTER TABLE posts ADD COLUMN guid TEXT;
+-- existing posts get the same link+title fallback the aggregator computes,
+-- items that do carry a guid take it over on their next fetch (AdoptLegacyPost)
+UPDATE posts SET guid = encode(sha256(convert_to(url || E'\n' || title, 'UTF8')), 'hex');
+ALTER TABLE posts ALTER COLUMN guid SET NOT NULL;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_guid_key UNIQUE (feed_id, guid);
+
+-- +goose Down
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_guid_key;
+ALTER TABLE posts DROP COLUMN guid;
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE (url);
+ALTER TABLE posts ADD CONSTRAINT posts_title_key UNIQUE (title);
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN locked_until TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN locked_until;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+-- paused feeds were stopped by a user, dead feeds by the aggregator after repeated failures
+ALTER TABLE feeds ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'paused', 'dead'));
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN status;
\ No newline at end of file
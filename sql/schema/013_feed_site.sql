@@ -0,0 +1,8 @@
+-- +goose Up
+-- taken from the feed document when it is added
+ALTER TABLE feeds ADD COLUMN site_url TEXT;
+ALTER TABLE feeds ADD COLUMN description TEXT;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN description;
+ALTER TABLE feeds DROP COLUMN site_url;
\ No newline at end of file
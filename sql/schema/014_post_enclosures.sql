@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE post_enclosures(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL,
+    url TEXT NOT NULL,
+    mime_type TEXT,
+    length BIGINT,
+    duration_seconds INTEGER,
+    -- images illustrating the post rather than carrying it
+    thumbnail BOOLEAN NOT NULL DEFAULT false,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    UNIQUE (post_id, url)
+);
+
+-- +goose Down
+DROP TABLE post_enclosures;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- +goose Up
+-- the full article, description keeps the summary
+ALTER TABLE posts ADD COLUMN content TEXT;
+ALTER TABLE post_revisions ADD COLUMN content TEXT;
+
+-- +goose Down
+ALTER TABLE post_revisions DROP COLUMN content;
+ALTER TABLE posts DROP COLUMN content;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+-- posts stored before guids were tracked, the only ones without a content
+-- hash, are matched by url when their item is next seen
+CREATE INDEX posts_legacy_url_idx ON posts (feed_id, url) WHERE content_hash IS NULL;
+
+-- +goose Down
+DROP INDEX posts_legacy_url_idx;
\ No newline at end of file